@@ -9,8 +9,11 @@ type HTTPMethodDescriptor struct {
 
 var methodTemplates = map[string]HTTPMethodDescriptor{
 	GET:    GET_desc,
+	HEAD:   GET_desc,
 	DELETE: DELETE_desc,
 	PUT:    PUT_desc,
+	POST:   POST_desc,
+	PATCH:  PATCH_desc,
 }
 
 // in: node, self, parent, id, otherIn; out: statusCode, entity, error
@@ -21,7 +24,7 @@ var GET_desc = HTTPMethodDescriptor{
 		return m.Manifest != nil
 	},
 	Invoke: func(m *compiled_methods, in *StandardHTTPMethodInputs) (int, interface{}, error) {
-		if entity, err := m.Manifest(in.Parent, in.ID); err != nil {
+		if entity, err := m.Manifest(in.Request, in.Parent, in.ID); err != nil {
 			return 500, nil, err
 		} else if entity == nil {
 			return 404, nil, nil
@@ -36,11 +39,11 @@ var DELETE_desc = HTTPMethodDescriptor{
 		return m.Delete != nil && m.Exists != nil
 	},
 	Invoke: func(m *compiled_methods, in *StandardHTTPMethodInputs) (int, interface{}, error) {
-		if exists, err := m.Exists(in.Parent, in.ID); err != nil {
+		if exists, err := m.Exists(in.Request, in.Parent, in.ID); err != nil {
 			return 500, nil, err
 		} else if !exists {
 			return 404, nil, nil
-		} else if err := m.Delete(nil, in.ID, in.Parent); err != nil {
+		} else if err := m.Delete(in.Request, in.Parent, in.ID, nil); err != nil {
 			return 500, nil, err
 		} else {
 			return 200, nil, nil
@@ -53,18 +56,79 @@ var PUT_desc = HTTPMethodDescriptor{
 		return m.Write != nil
 	},
 	Invoke: func(m *compiled_methods, in *StandardHTTPMethodInputs) (int, interface{}, error) {
-		if exists, err := m.Exists(in.Parent, in.ID); err != nil {
+		if exists, err := m.Exists(in.Request, in.Parent, in.ID); err != nil {
 			return 500, nil, err
+		} else if err := validate(m, in.Request, in.Parent, in.ID, in.Self); err != nil {
+			return 500, nil, err
+		} else if err := m.Write(in.Request, in.Parent, in.ID, in.Self); err != nil {
+			return 500, nil, err
+		} else if exists {
+			return 200, in.Self, nil
 		} else {
-			successStatus := 201
-			if exists {
-				successStatus = 200
-			}
-			if err := m.Write(in.Self, in.ID, in.Parent); err != nil {
-				return 500, nil, err
-			}
-			return successStatus, in.Self, nil
+			return 201, in.Self, nil
 		}
 	},
 	RequiresEntity: true,
 }
+
+// Processed may be returned by Process methods to choose the status code of
+// the response, e.g. 201 Created with the Location of the new resource, or
+// 202 Accepted. Results which are not wrapped in Processed, or which have a
+// zero StatusCode, are sent with 200. StatusCode must otherwise be 2xx; errors
+// are returned as errors. Location, if set, is also the result's self link.
+type Processed struct {
+	StatusCode int
+	Entity     interface{}
+	Location   string
+}
+
+var POST_desc = HTTPMethodDescriptor{
+	IsSupported: func(m *compiled_methods) bool {
+		return m.Process != nil
+	},
+	Invoke: func(m *compiled_methods, in *StandardHTTPMethodInputs) (int, interface{}, error) {
+		if result, err := m.Process(in.Request, in.Parent, in.ID, in.Posted); err != nil {
+			return 500, nil, err
+		} else if p, ok := result.(Processed); ok {
+			if p.StatusCode == 0 {
+				p.StatusCode = 200
+			} else if p.StatusCode < 200 || p.StatusCode > 299 {
+				return 500, nil, Error("Process returned Processed with StatusCode ", p.StatusCode, ", it must be 2xx; return an error instead.")
+			}
+			return p.StatusCode, p, nil
+		} else {
+			return 200, result, nil
+		}
+	},
+	RequiresOtherPayload: true,
+}
+
+var PATCH_desc = HTTPMethodDescriptor{
+	IsSupported: func(m *compiled_methods) bool {
+		return m.Manifest != nil && m.Write != nil
+	},
+	Invoke: func(m *compiled_methods, in *StandardHTTPMethodInputs) (int, interface{}, error) {
+		if current, err := m.Manifest(in.Request, in.Parent, in.ID); err != nil {
+			return 500, nil, err
+		} else if current == nil {
+			return 404, nil, nil
+		} else if patched, err := in.Patch(current); err != nil {
+			return 500, nil, err
+		} else if err := validate(m, in.Request, in.Parent, in.ID, patched); err != nil {
+			return 500, nil, err
+		} else if err := m.Write(in.Request, in.Parent, in.ID, patched); err != nil {
+			return 500, nil, err
+		} else {
+			return 200, patched, nil
+		}
+	},
+	RequiresOtherPayload: true,
+}
+
+// validate calls the user's Validate method, if there is one.
+func validate(m *compiled_methods, r *Request, parent interface{}, id string, self interface{}) error {
+	if m.Validate == nil {
+		return nil
+	}
+	return m.Validate(r, parent, id, self)
+}
@@ -0,0 +1,100 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Mailbox struct {
+	Count int `json:"count"`
+}
+
+func (m *Mailbox) Manifest() error {
+	return nil
+}
+
+type Message struct {
+	Status int    `json:"status"`
+	Text   string `json:"text"`
+}
+
+// Process answers with the status code the message asks for, as 201 Created
+// at /messages/1 or with no Location otherwise.
+func (m *Mailbox) Process(msg *Message) (interface{}, error) {
+	if msg.Status == 201 {
+		return Processed{StatusCode: 201, Entity: msg, Location: "/messages/1"}, nil
+	}
+	return Processed{StatusCode: msg.Status, Entity: msg}, nil
+}
+
+func Test_ServeHTTP_POST(t *testing.T) {
+	w := serve(t, "POST", "/apps/test-app/1.2.3", `{"environment":"staging"}`)
+	if doc := halBody(t, w); w.Code != 202 || doc["version"] != "1.2.3" || doc["environment"] != "staging" {
+		t.Errorf("Expected 202 deploying 1.2.3 to staging but got %v %s", w.Code, w.Body)
+	}
+	w = serve(t, "POST", "/apps/test-app/1.2.3", `{"environment":"qa"}`)
+	if w.Code != 422 || !strings.Contains(w.Body.String(), `"pointer": "/environment"`) {
+		t.Errorf("Expected 422 for /environment but got %v %s", w.Code, w.Body)
+	}
+	w = serve(t, "POST", "/apps/test-app/9.9.9", `{"environment":"staging"}`)
+	if w.Code != 404 {
+		t.Errorf("Expected 404 but got %v %s", w.Code, w.Body)
+	}
+}
+
+func Test_ServeHTTP_POST_Processed(t *testing.T) {
+	g, err := Graph(Mailbox{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		body     string
+		code     int
+		location string
+		self     string
+	}{
+		{`{"status":201}`, 201, "/messages/1", "/messages/1"},
+		{`{"status":202}`, 202, "", ""},
+		{`{"status":0}`, 200, "", ""},
+		{`{"status":302}`, 500, "", ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader(c.body)))
+		if w.Code != c.code || w.Header().Get("Location") != c.location {
+			t.Errorf("POST %s: expected %v with Location %q but got %v %q", c.body, c.code, c.location, w.Code, w.Header().Get("Location"))
+		} else if w.Code < 300 {
+			if self, _ := lookup(halBody(t, w), "_links", "self", "href").(string); self != c.self {
+				t.Errorf("POST %s: expected self link %q but got %q", c.body, c.self, self)
+			}
+		}
+	}
+}
+
+func Test_ServeHTTP_PUT(t *testing.T) {
+	defer delete(the_apps["test-app"].Versions, "1.4.0")
+	body := `{"id":"test-app-1-4-0","name":"test-app","version":"1.4.0"}`
+	w := serve(t, "PUT", "/apps/test-app/1.4.0", body)
+	if w.Code != 201 || w.Header().Get("Location") != "/apps/test-app/1.4.0" {
+		t.Errorf("Expected 201 with Location /apps/test-app/1.4.0 but got %v %q", w.Code, w.Header().Get("Location"))
+	}
+	w = serve(t, "PUT", "/apps/test-app/1.4.0", `{"id":"changed","name":"test-app","version":"1.4.0"}`)
+	if w.Code != 200 || w.Header().Get("Location") != "" {
+		t.Errorf("Expected 200 without Location overwriting 1.4.0 but got %v %q", w.Code, w.Header().Get("Location"))
+	} else if id := the_apps["test-app"].Versions["1.4.0"].ID; id != "changed" {
+		t.Errorf("Expected 1.4.0 to have been overwritten but its id is %q", id)
+	}
+	if w = serve(t, "PUT", "/apps/test-app/1.4.0", `{bad`); w.Code != 400 || !strings.Contains(w.Body.String(), "Malformed AppVersion: invalid JSON at offset 2.") {
+		t.Errorf("Expected 400 for a malformed body but got %v %s", w.Code, w.Body)
+	}
+	if w = serve(t, "PUT", "/apps/test-app/1.4.0", `{"id":5}`); w.Code != 400 || !strings.Contains(w.Body.String(), "'id' cannot be a JSON number.") {
+		t.Errorf("Expected 400 for a mistyped field but got %v %s", w.Code, w.Body)
+	} else if strings.Contains(w.Body.String(), "halgo.") || strings.Contains(w.Body.String(), `"id":5`) {
+		t.Errorf("Expected the problem not to repeat the body or Go types but got %s", w.Body)
+	}
+	w = serve(t, "PUT", "/apps/test-app/1.4.0", `{"id":"x","name":"test-app","version":"1.5.0"}`)
+	if w.Code != 422 || !strings.Contains(w.Body.String(), "must match the version in the URL") {
+		t.Errorf("Expected 422 from Validate but got %v %s", w.Code, w.Body)
+	}
+}
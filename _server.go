@@ -23,7 +23,7 @@ func (s server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	Print("request ", r.URL)
 	if response, err := s.process_request(r); err != nil {
 		if httpError, ok := err.(HTTPError); ok {
-			w.WriteHeader(httpError.StatusCode)
+			w.WriteHeader(httpError.StatusCode())
 		} else {
 			w.WriteHeader(500)
 		}
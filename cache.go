@@ -0,0 +1,46 @@
+package halgo
+
+import (
+	"net/http"
+	"time"
+)
+
+// Modified may be implemented by entities which know when they last changed.
+// Their GET responses carry Last-Modified, and honour If-Modified-Since.
+type Modified interface {
+	LastModified() time.Time
+}
+
+// setCacheHeaders sets the Cache-Control, Last-Modified and ETag headers for
+// a successful GET of res, and reports whether the client's copy is still
+// fresh, according to If-None-Match or, failing that, If-Modified-Since. It
+// returns the rendered body if it had to be rendered to derive the ETag.
+func setCacheHeaders(w http.ResponseWriter, r *http.Request, renderer Renderer, res *Resource) ([]byte, bool, error) {
+	if res.Node != nil && res.Node.CacheControl != "" {
+		w.Header().Set("Cache-Control", res.Node.CacheControl)
+	}
+	if !res.LastModified.IsZero() {
+		w.Header().Set("Last-Modified", res.LastModified.UTC().Format(http.TimeFormat))
+	}
+	etag, body, err := resourceETag(renderer, res)
+	if err != nil {
+		return nil, false, err
+	}
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+		return body, anyETagMatches(ifNoneMatch, true, []string{etag}, true), nil
+	}
+	return body, notModifiedSince(r.Header.Get("If-Modified-Since"), res.LastModified), nil
+}
+
+// notModifiedSince reports whether lastModified is no later than the time in
+// an If-Modified-Since header. HTTP dates have a resolution of one second.
+func notModifiedSince(header string, lastModified time.Time) bool {
+	if header == "" || lastModified.IsZero() {
+		return false
+	} else if since, err := http.ParseTime(header); err != nil {
+		return false
+	} else {
+		return !lastModified.Truncate(time.Second).After(since)
+	}
+}
@@ -0,0 +1,86 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+var ledger_modified = time.Date(2015, 3, 14, 9, 26, 53, 589000000, time.UTC)
+
+type DatedLedger struct {
+	Balance int `json:"balance"`
+}
+
+func (l *DatedLedger) Manifest() error {
+	l.Balance = 10
+	return nil
+}
+
+func (l *DatedLedger) LastModified() time.Time {
+	return ledger_modified
+}
+
+func Test_notModifiedSince(t *testing.T) {
+	cases := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"not a date", false},
+		{"Sat, 14 Mar 2015 09:26:53 GMT", true},
+		{"Sat, 14 Mar 2015 09:26:52 GMT", false},
+		{"Sun, 15 Mar 2015 00:00:00 GMT", true},
+	}
+	for _, c := range cases {
+		if actual := notModifiedSince(c.header, ledger_modified); actual != c.expected {
+			t.Errorf("If-Modified-Since: %q: expected %v but got %v", c.header, c.expected, actual)
+		}
+	}
+}
+
+func Test_ServeHTTP_LastModified(t *testing.T) {
+	g, err := Graph(DatedLedger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	lastModified := w.Header().Get("Last-Modified")
+	if lastModified != "Sat, 14 Mar 2015 09:26:53 GMT" {
+		t.Errorf("Expected Last-Modified: Sat, 14 Mar 2015 09:26:53 GMT but got %q", lastModified)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-Modified-Since", lastModified)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != 304 {
+		t.Errorf("Expected 304 but got %v", w.Code)
+	}
+
+	// If-None-Match takes precedence over If-Modified-Since.
+	r.Header.Set("If-None-Match", `"stale"`)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != 200 {
+		t.Errorf("Expected 200 but got %v", w.Code)
+	}
+}
+
+func Test_ServeHTTP_CacheControl(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, expected := range map[string]string{"/": "", "/apps": "max-age=60, public"} {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if actual := w.Header().Get("Cache-Control"); actual != expected {
+			t.Errorf("GET %s: expected Cache-Control %q but got %q", path, expected, actual)
+		}
+		if vary := w.Header().Get("Vary"); vary != "Accept" {
+			t.Errorf("GET %s: expected Vary: Accept but got %q", path, vary)
+		}
+	}
+}
@@ -1,12 +1,13 @@
 package halgo
 
 const (
-	HEAD   = "HEAD"
-	GET    = "GET"
-	DELETE = "DELETE"
-	PUT    = "PUT"
-	PATCH  = "PATCH"
-	POST   = "POST"
+	HEAD    = "HEAD"
+	GET     = "GET"
+	DELETE  = "DELETE"
+	PUT     = "PUT"
+	PATCH   = "PATCH"
+	POST    = "POST"
+	OPTIONS = "OPTIONS"
 )
 
 var parameter_specs map[string]parameter_spec = map[string]parameter_spec{
@@ -6,59 +6,106 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"log"
 	"net/http"
+	"net/url"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
 var error_T = reflect.TypeOf((*error)(nil)).Elem()
 
-func (root *Node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	if statusCode, entity, err := root.serve(r); err != nil {
-		writeError(w, err)
+func (root *Node) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	sw := &startedResponseWriter{ResponseWriter: rw}
+	defer root.recoverPanic(sw, r)
+	var w http.ResponseWriter = sw
+	if r.Method == HEAD {
+		w = headResponseWriter{w}
+	}
+	if statusCode, entity, err := root.serve(w, r); err != nil {
+		writeError(w, r, err)
+	} else if entity == nil && statusCode >= 400 {
+		writeError(w, r, HttpError(statusCode, http.StatusText(statusCode)))
+	} else if entity == nil {
+		w.WriteHeader(statusCode)
+	} else if res, ok := entity.(*Resource); ok {
+		root.writeResource(w, r, statusCode, res)
+	} else if body, err := marshalIndent(entity); err != nil {
+		writeError(w, r, Error("Unable to serialise entity: ", err))
 	} else {
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(statusCode)
-		if body, err := json.MarshalIndent(entity, "", "\t"); err != nil {
-			panic("Unable to serialise entity: " + err.Error())
-		} else {
-			w.Write(body)
-		}
+		w.Write(body)
 	}
 }
 
-func writeError(w http.ResponseWriter, err error) {
-	switch e := err.(type) {
-	case HTTPError:
-		writeHttpError(w, e)
-	default:
-		w.WriteHeader(500)
-		w.Write(serialise(err))
+func (root *Node) writeResource(w http.ResponseWriter, r *http.Request, statusCode int, res *Resource) {
+	w.Header().Add("Vary", "Accept")
+	renderer, ok := negotiate(r.Header.Get("Accept"), root.Renderers)
+	if !ok {
+		writeError(w, r, HttpError(406, "Acceptable media types are: ", root.mediaTypes()))
+		return
 	}
-}
-
-func writeHttpError(w http.ResponseWriter, err HTTPError) {
-	w.WriteHeader(err.StatusCode)
-	w.Write(serialise(err.HalgoError))
-}
-
-func serialise(a interface{}) []byte {
-	if data, err := json.Marshal(a); err != nil {
-		panic(err)
+	var body []byte
+	var err error
+	if statusCode == 200 && (r.Method == GET || r.Method == HEAD) {
+		var notModified bool
+		if body, notModified, err = setCacheHeaders(w, r, renderer, res); err == nil && notModified {
+			w.WriteHeader(304)
+			return
+		}
+	}
+	if body == nil && err == nil {
+		body, err = renderer.Render(res)
+	}
+	if err != nil {
+		writeError(w, r, Error("Unable to serialise entity: ", err))
 	} else {
-		return data
+		w.Header().Set("Content-Type", renderer.MediaType())
+		w.WriteHeader(statusCode)
+		w.Write(body)
 	}
 }
 
-func (root *Node) serve(r *http.Request) (int, interface{}, error) {
-	target_href := strings.Split(r.URL.String(), "?")[0]
-	if n, statusCode, entity, err := root.serveMainEntity(r); err != nil {
+func (root *Node) serve(w http.ResponseWriter, r *http.Request) (int, interface{}, error) {
+	req := &Request{r}
+	n, statusCode, entity, err := root.serveMainEntity(req)
+	self_href := r.URL.Path
+	if p, ok := entity.(Processed); ok {
+		statusCode, entity = p.StatusCode, p.Entity
+		if p.Location != "" {
+			w.Header().Set("Location", p.Location)
+			self_href = p.Location
+		}
+	}
+	if n != nil && n.SupportsPATCH() {
+		w.Header().Set("Accept-Patch", acceptPatch)
+	}
+	if r.Method == PUT && statusCode == 201 {
+		w.Header().Set("Location", r.URL.Path)
+	}
+	if n != nil && (r.Method == OPTIONS || errorStatus(err) == 405) {
+		w.Header().Set("Allow", n.AllowedMethods().String())
+	}
+	if err != nil {
 		return 0, nil, err
-	} else if m, err := toMap(entity); err != nil {
+	} else if n == nil || entity == nil || r.Method == OPTIONS {
+		return statusCode, entity, nil
+	}
+	if r.Method == POST && w.Header().Get("Location") == "" && reflect.TypeOf(entity) != n.EntityPtrType {
+		// This result is not the resource posted to, and Process did not
+		// say where it is, so it has no self link.
+		self_href = ""
+	}
+	if v, err := n.parseView(req, root.MaxEmbedDepth); err != nil {
 		return 0, nil, err
-	} else if children, err := n.manifestChildren(entity, target_href); err != nil {
+	} else if res, err := n.makeResource(entity, self_href, v); err != nil {
 		return 0, nil, err
 	} else {
-		return statusCode, resource{Entity: *m, Embedded: children, Links: nil}, err
+		return statusCode, res, nil
 	}
 }
 
@@ -73,53 +120,316 @@ func toMap(resource interface{}) (*map[string]interface{}, error) {
 	}
 }
 
-type resource struct {
-	Entity   map[string]interface{}
+// Resource is a manifested entity along with its hypermedia. It serialises
+// itself as a HAL document, with the entity's properties at the top level.
+type Resource struct {
+	// Node is the node in the graph this resource was manifested from.
+	Node   *Node
+	Entity map[string]interface{}
+	// Embedded maps rels to either a *Resource or a []*Resource.
 	Embedded map[string]interface{}
-	Links    map[string]interface{}
+	// Links maps rels to a Link.
+	Links map[string]interface{}
+	// Version is the entity's own version, if it is Versioned.
+	Version string
+	// view is the canonical form of the view parameters the resource was
+	// shaped by, which distinguishes the ETags of Versioned resources.
+	view string
+	// LastModified is when the entity last changed, if it is Modified.
+	LastModified time.Time
 }
 
-func (n *Node) manifestChildren(parent interface{}, parent_href string) (map[string]interface{}, error) {
+func (r *Resource) MarshalJSON() ([]byte, error) {
+	hal := map[string]interface{}{}
+	for k, v := range r.Entity {
+		hal[k] = v
+	}
+	if len(r.Links) != 0 {
+		hal["_links"] = r.Links
+	}
+	if len(r.Embedded) != 0 {
+		hal["_embedded"] = r.Embedded
+	}
+	return marshal(hal)
+}
+
+// makeResource builds the HAL resource for entity, shaped by the view the
+// client asked for.
+func (n *Node) makeResource(entity interface{}, self_href string, v view) (*Resource, error) {
+	if m, err := toMap(entity); err != nil {
+		return nil, err
+	} else if reflect.TypeOf(entity) != n.EntityPtrType {
+		// This is some other entity, e.g. the result of a POST, so it only
+		// gets a self link, if it has an href at all.
+		r := &Resource{Entity: v.project(*m), Links: map[string]interface{}{}}
+		if self_href != "" {
+			r.Links["self"] = Link{Href: self_href}
+		}
+		return r, nil
+	} else {
+		r := &Resource{
+			Node:     n,
+			Entity:   v.project(*m),
+			Embedded: map[string]interface{}{},
+			Links:    map[string]interface{}{"self": Link{Href: self_href}},
+		}
+		if up, ok := parentHref(self_href); ok {
+			r.Links["up"] = Link{Href: up}
+		}
+		if versioned, ok := entity.(Versioned); ok {
+			r.Version, r.view = versioned.Version(), v.canonical()
+		}
+		if modified, ok := entity.(Modified); ok {
+			r.LastModified = modified.LastModified()
+		}
+		return r, n.manifestChildren(r, entity, self_href, v)
+	}
+}
+
+func (n *Node) manifestChildren(r *Resource, parent interface{}, parent_href string, v view) error {
 	parent_href = strings.TrimSuffix(parent_href, "/")
-	children := map[string]interface{}{}
+	if n.ID_Child != nil {
+		return n.ID_Child.manifestCollection(r, parent, parent_href, v)
+	}
 	for k, c := range *n.Children {
-		switch c.Meta.expansion.expansion_type {
-		case href:
-			children[k] = map[string]string{"href": parent_href + "/" + k}
-		case all:
-			println("Expanding " + parent_href + "/" + k)
-			child_node, _, _, _ := n.Resolve([]string{k}, "", parent)
-			_, child, err := child_node.HTTPMethods["GET"].Invoke(&child_node.Methods, &StandardHTTPMethodInputs{Parent: parent, ID: k})
-			if err != nil {
-				children[k] = err
+		delete(r.Entity, c.Property)
+		child_href := parent_href + "/" + k
+		rel := c.Rel()
+		r.Links[rel] = Link{Href: child_href}
+		e, child_view := c.expansionFor(v)
+		if e == href {
+			// The link above is all we need.
+			continue
+		}
+		if entity, err := c.Node.Methods.Manifest(v.req, parent, k); err != nil {
+			return err
+		} else if child, err := c.embed(entity, child_href, e, child_view); err != nil {
+			return err
+		} else {
+			r.Embedded[rel] = child
+		}
+	}
+	return nil
+}
+
+// manifestCollection embeds each member of the collection c, read from the
+// parent entity's map or slice field, as an array of HAL resources. Members
+// of slices are addressed by their index.
+func (c *Child) manifestCollection(r *Resource, parent interface{}, parent_href string, v view) error {
+	delete(r.Entity, c.Property)
+	rel := c.Rel()
+	r.Links[rel] = Link{Href: parent_href + "/{" + c.Var() + "}", Templated: true}
+	e, item_view := c.expansionFor(v)
+	if members, err := c.manifestMembers(r, parent, parent_href, v); err != nil {
+		return err
+	} else {
+		items := []*Resource{}
+		for _, m := range members {
+			if item, err := c.embed(m.entity, parent_href+"/"+url.PathEscape(m.id), e, item_view); err != nil {
+				return err
 			} else {
-				children[k] = child
+				items = append(items, item)
 			}
-		case fields:
-			children[k] = "fields tag not yet supported."
-		default:
-			children[k] = fmt.Sprintf("Child type %v not recognised.", c.Meta.expansion.expansion_type)
 		}
+		r.Embedded[rel] = items
+		return nil
+	}
+}
+
+type member struct {
+	id     string
+	entity interface{}
+}
+
+// manifestMembers returns the members of the collection c, filtered and
+// sorted as the client asked. The collection's own Query method is used for
+// that if it has one, which also pages its results if the collection is
+// pageable. Otherwise members are either paged through, if its type
+// implements Page, or else read from the parent entity, and then filtered and
+// sorted in memory. As filtering a single page would give wrong results, a
+// collection which implements Page but not Query cannot be filtered or sorted.
+//
+// Members read from the parent entity are each loaded with the member type's
+// Manifest, so that they are embedded just as a GET of them would return
+// them. Members returned by Page or Query are embedded as those methods
+// return them, as they exist to load members in bulk.
+func (c *Child) manifestMembers(r *Resource, parent interface{}, parent_href string, v view) ([]member, error) {
+	if !v.filter.IsEmpty() && c.Query != nil {
+		q := v.filter
+		if c.Page != nil {
+			q.Cursor, q.Limit = v.page.cursor, v.page.limit
+		}
+		collection, cursors, err := c.Query(v.req, parent, q)
+		if err != nil {
+			return nil, err
+		}
+		members, err := collectionMembers(collection, c.Name)
+		if err == nil && reflect.Indirect(collection).Kind() == reflect.Map {
+			// Maps have no order, so must be sorted here.
+			members, err = Query{Sort: v.filter.Sort}.apply(members)
+		}
+		if err == nil && c.Page != nil {
+			v.addPageLinks(r, parent_href, cursors)
+		}
+		return members, err
+	} else if !v.filter.IsEmpty() && c.Page != nil {
+		return nil, HttpError(400, "Collection '", c.Rel(), "' is paged, so cannot be filtered or sorted unless it implements Query.")
+	}
+	if c.Page == nil {
+		members, err := c.members(v.req, parent)
+		if err != nil || v.filter.IsEmpty() {
+			return members, err
+		}
+		return v.filter.apply(members)
+	} else if collection, cursors, err := c.Page(v.req, parent, v.page.cursor, v.page.limit); err != nil {
+		return nil, err
+	} else {
+		v.addPageLinks(r, parent_href, cursors)
+		return collectionMembers(collection, c.Name)
+	}
+}
+
+// members returns the members of the collection c in the parent entity, each
+// manifested by its own Manifest method. Members it reports as not found are
+// left out.
+func (c *Child) members(r *Request, parent interface{}) ([]member, error) {
+	v := reflect.Indirect(reflect.ValueOf(parent))
+	if !v.IsValid() {
+		return []member{}, nil
+	}
+	members, err := collectionMembers(v.FieldByName(c.Name), c.Name)
+	if err != nil {
+		return nil, err
+	}
+	found := []member{}
+	for _, m := range members {
+		if entity, err := c.Node.Methods.Manifest(r, parent, m.id); isNotFound(err) {
+			continue
+		} else if err != nil {
+			return nil, err
+		} else {
+			found = append(found, member{m.id, entity})
+		}
+	}
+	return found, nil
+}
+
+// collectionMembers returns the members of the map or slice f, sorted by key.
+func collectionMembers(f reflect.Value, name string) ([]member, error) {
+	members := []member{}
+	for f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return members, nil
+		}
+		f = f.Elem()
+	}
+	switch f.Kind() {
+	case reflect.Map:
+		keys := f.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, k := range keys {
+			members = append(members, member{fmt.Sprint(k), pointerTo(f.MapIndex(k))})
+		}
+	case reflect.Slice:
+		for i := 0; i < f.Len(); i++ {
+			members = append(members, member{strconv.Itoa(i), pointerTo(f.Index(i))})
+		}
+	default:
+		return nil, Error(name, " is a collection child, but is a ", f.Kind(), " rather than a map or slice.")
+	}
+	return members, nil
+}
+
+// pointerTo returns a pointer to a copy of v, which is how entities are passed
+// to user methods.
+func pointerTo(v reflect.Value) interface{} {
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Interface()
+}
+
+// expansionFor returns how this child should be embedded, which is as
+// declared by its embed(...) tag unless the client asked for it in full, and
+// the view to render it with.
+func (c *Child) expansionFor(v view) (expansion_type, view) {
+	rel := c.Rel()
+	if sub, ok := v.embed[rel]; ok {
+		return all, v.child(rel, sub)
+	}
+	return c.Meta.expansion.expansion_type, v.child(rel, nil)
+}
+
+// embed renders entity as it should appear in its parent's _embedded section.
+func (c *Child) embed(entity interface{}, self_href string, e expansion_type, v view) (*Resource, error) {
+	self := map[string]interface{}{"self": Link{Href: self_href}}
+	switch e {
+	case href:
+		return &Resource{Node: c.Node, Links: self}, nil
+	case all:
+		return c.Node.makeResource(entity, self_href, v)
+	case fields:
+		if m, err := toMap(entity); err != nil {
+			return nil, err
+		} else {
+			return &Resource{Node: c.Node, Entity: v.project(selectFields(*m, c.Meta.expansion.fields)), Links: self}, nil
+		}
+	default:
+		return nil, Error("Child type ", e, " not recognised.")
 	}
-	return children, nil
 }
 
-func (root *Node) serveMainEntity(r *http.Request) (*Node, int, interface{}, error) {
+// selectFields returns only those properties of m which are named in fields.
+func selectFields(m map[string]interface{}, fields []string) map[string]interface{} {
+	selected := map[string]interface{}{}
+	for _, f := range fields {
+		if v, ok := m[f]; ok {
+			selected[f] = v
+		}
+	}
+	return selected
+}
+
+// parentHref returns the href one level above href, and false if href is
+// the root.
+func parentHref(href string) (string, bool) {
+	href = strings.TrimSuffix(href, "/")
+	if href == "" {
+		return "", false
+	} else if i := strings.LastIndex(href, "/"); i <= 0 {
+		return "/", true
+	} else {
+		return href[:i], true
+	}
+}
+
+func (root *Node) serveMainEntity(r *Request) (*Node, int, interface{}, error) {
 	path := strings.Split(r.URL.Path[1:], "/")
-	if target_node, parent_entity, id, err := root.Resolve(path, "", nil); err != nil {
+	if target_node, parent_entity, id, err := root.Resolve(r, path, "", nil); err != nil {
 		return nil, 0, nil, err
+	} else if r.Method == OPTIONS {
+		return target_node, 200, root.describe(target_node, r.URL.Path), nil
 	} else if method, ok := target_node.HTTPMethods[r.Method]; !ok {
-		return nil, 405, target_node.MethodNotSupportedBody(), nil
+		return target_node, 0, nil, HttpError(405, r.Method, " not supported. ", target_node.MethodNotSupportedBody())
 	} else if err != nil {
 		return nil, 0, nil, err
 	} else {
 		in := makeStdInputs(parent_entity, id, target_node, r)
+		if r.Method == PUT || r.Method == PATCH || r.Method == DELETE {
+			if err := root.checkPreconditions(r, target_node, in); err != nil {
+				return target_node, 0, nil, err
+			}
+		}
+		if method.RequiresEntity {
+			if in.Self, err = in.Posted(target_node.EntityPtrType); err != nil {
+				return target_node, 0, nil, err
+			}
+		}
 		statusCode, entity, err := method.Invoke(&target_node.Methods, in)
 		return target_node, statusCode, entity, err
 	}
 }
 
-func (n *Node) Resolve(path []string, id string, parent interface{}) (endpoint *Node, endpointParent interface{}, endpointID string, err error) {
+func (n *Node) Resolve(r *Request, path []string, id string, parent interface{}) (endpoint *Node, endpointParent interface{}, endpointID string, err error) {
 
 	if len(path) == 0 || (len(path) == 1 && len(path[0]) == 0) {
 		// This is either the end of the path, so return what we have.
@@ -138,33 +448,43 @@ func (n *Node) Resolve(path []string, id string, parent interface{}) (endpoint *
 
 	// Now, we manifest the current node's entity, to use as the parent for
 	// the next (child) node.
-	if entity, err := n.Methods.Manifest(parent, id); err != nil {
+	if entity, err := n.Methods.Manifest(r, parent, id); err != nil {
 		return nil, nil, "", err
 	} else if entity == nil {
 		// This node does not exist, so we can't move to the child.
-		return nil, nil, "", nil
+		return nil, nil, "", Error404(strings.Join(append([]string{id}, path...), "/"))
 	} else {
-		return child.Node.Resolve(path[1:], path[0], entity)
+		return child.Node.Resolve(r, path[1:], path[0], entity)
 	}
 }
 
-func makeStdInputs(parent interface{}, id string, n *Node, r *http.Request) *StandardHTTPMethodInputs {
-	in := &StandardHTTPMethodInputs{Node: n}
+func makeStdInputs(parent interface{}, id string, n *Node, r *Request) *StandardHTTPMethodInputs {
+	in := &StandardHTTPMethodInputs{Node: n, Request: r}
 	if n.IsIdentity != nil && *n.IsIdentity {
 		in.ID = id
 	}
 	if parent != nil {
 		in.Parent = parent
 	}
+	in.Posted = func(t reflect.Type) (interface{}, error) {
+		return decodeBody(r.Body, t)
+	}
+	in.Patch = func(entity interface{}) (interface{}, error) {
+		return patchEntity(entity, r.Header.Get("Content-Type"), r.Body)
+	}
 	return in
 }
 
 type StandardHTTPMethodInputs struct {
-	Node   HttpNode
-	Self   interface{}
-	Parent interface{}
-	ID     string
-	Posted func() interface{}
+	// Request is the request being served.
+	Request *Request
+	Node    HttpNode
+	Self    interface{}
+	Parent  interface{}
+	ID      string
+	Posted  func(reflect.Type) (interface{}, error)
+	// Patch applies the request's patch document to an entity.
+	Patch func(interface{}) (interface{}, error)
 }
 
 type list []string
@@ -178,6 +498,11 @@ func (l *list) String() string {
 }
 
 func (n *Node) MethodNotSupportedBody() error {
+	return Error("Supported Methods: ", n.AllowedMethods())
+}
+
+// AllowedMethods lists the methods n supports, as sent in the Allow header.
+func (n *Node) AllowedMethods() *list {
 	supported := &list{}
 	if n.SupportsGET() {
 		supported.Add("HEAD")
@@ -195,7 +520,8 @@ func (n *Node) MethodNotSupportedBody() error {
 	if n.SupportsPOST() {
 		supported.Add("POST")
 	}
-	return Error("Supported Methods: ", supported)
+	supported.Add("OPTIONS")
+	return supported
 }
 
 func processPutResponse(statusCode int, entity interface{}, err error) *RESP {
@@ -245,11 +571,23 @@ func InternalServerError(message string) *RESP {
 	return r
 }
 
+// Graph builds the tree of nodes served for the type of root. It checks the
+// whole tree before returning, so if there are any problems the error is a
+// GraphErrors listing all of them.
 func Graph(root interface{}) (HttpNode, error) {
-	return graph(reflect.TypeOf(root), nil)
+	t := entityType(reflect.TypeOf(root))
+	errs := GraphErrors{}
+	n := graph(t, nil, graphPath{"/", t.Name()}, &errs)
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	n.MaxEmbedDepth = DefaultMaxEmbedDepth
+	n.Renderers = DefaultRenderers()
+	return n, nil
 }
 
-func graph(t reflect.Type, parent reflect.Type) (HttpNode, error) {
+// entityType is the type of entity served for a field of type t.
+func entityType(t reflect.Type) reflect.Type {
 	if t.Kind() == reflect.Ptr {
 		t = t.Elem()
 	}
@@ -258,46 +596,82 @@ func graph(t reflect.Type, parent reflect.Type) (HttpNode, error) {
 	} else if t.Kind() == reflect.Slice {
 		t = t.Elem()
 	}
+	return t
+}
+
+func graph(t reflect.Type, parent reflect.Type, at graphPath, errs *GraphErrors) *Node {
 	n := &Node{EntityType: t, EntityPtrType: reflect.PtrTo(t), ParentType: parent}
+	if _, err := rulesFor(t); err != nil {
+		errs.add(at, err)
+	}
 	if err := n.CompileMethods(); err != nil {
-		return nil, err
-	} else if err := n.AddChildren(); err != nil {
-		return nil, err
+		errs.add(at, err)
 	}
-	return n, nil
+	n.addChildren(at, errs)
+	return n
 }
 
+// AddChildren builds a node for each child of n, returning the first problem
+// found, if any.
 func (n *Node) AddChildren() error {
+	errs := GraphErrors{}
+	n.addChildren(graphPath{"/", n.EntityType.Name()}, &errs)
+	if len(errs) != 0 {
+		return errs[0]
+	}
+	return nil
+}
+
+func (n *Node) addChildren(at graphPath, errs *GraphErrors) {
 	members := map[string]*Child{}
 	collections := []*Child{}
 	numFields := n.EntityType.NumField()
 	for i := 0; i < numFields; i++ {
 		f := n.EntityType.Field(i)
+		field_at, child_at := at.child(f)
 		if meta, err := getMetadata(f); err != nil {
-			return err
+			errs.add(field_at, err)
 		} else if meta.expansion != nil {
-			if childNode, err := graph(f.Type, n.EntityPtrType); err != nil {
-				return err
-			} else {
-				c := &Child{childNode.Node(), meta, f.Type.Kind()}
-				if childNode.IsID() {
-					collections = append(collections, c)
-				} else {
-					members[strings.ToLower(f.Name)] = c
+			childNode := graph(entityType(f.Type), n.EntityPtrType, child_at, errs)
+			c := &Child{childNode, meta, f.Type.Kind(), f.Name, jsonName(f), nil, nil}
+			if meta.cache_control != nil {
+				childNode.CacheControl = *meta.cache_control
+			}
+			childNode.RequirePreconditions = meta.require_preconditions
+			if isCollection(childNode, f) {
+				var err error
+				if c.Page, err = n.compilePage(f.Type); err != nil {
+					errs.add(field_at, err)
 				}
+				if c.Query, err = n.compileQuery(f.Type, c.Page != nil); err != nil {
+					errs.add(field_at, err)
+				}
+				collections = append(collections, c)
+			} else {
+				members[strings.ToLower(f.Name)] = c
 			}
 		}
 	}
 	if len(collections) > 1 {
-		return Error(n, "contains more than one collection child")
+		errs.add(at, Error("contains more than one collection child"))
 	} else if len(collections) == 1 && len(members) != 0 {
-		return Error(n, "contains a collection child and named members")
+		errs.add(at, Error("contains a collection child and named members"))
 	} else if len(collections) == 1 {
 		n.ID_Child = collections[0]
 	} else {
 		n.Children = &members
 	}
-	return nil
+}
+
+// isCollection reports whether the child node for field f is a collection
+// member. If the child's methods could not be compiled, the field's kind is
+// used instead.
+func isCollection(child *Node, f reflect.StructField) bool {
+	if child.IsIdentity != nil {
+		return child.IsID()
+	}
+	k := f.Type.Kind()
+	return k == reflect.Map || k == reflect.Slice
 }
 
 type Node struct {
@@ -309,6 +683,31 @@ type Node struct {
 	IsIdentity    *bool
 	ID_Child      *Child
 	HTTPMethods   map[string]HTTPMethodDescriptor
+	// MaxEmbedDepth limits how many levels deep a client may ask for
+	// children to be embedded using ?embed=. Only the root node's value is
+	// used.
+	MaxEmbedDepth int
+	// Renderers are the representations this node can be served as, in
+	// order of preference. Only the root node's value is used.
+	Renderers []Renderer
+	// Logger receives panics recovered while serving requests. If nil, the
+	// standard logger is used. Only the root node's value is used.
+	Logger *log.Logger
+	// Repanic re-raises recovered panics after logging them, rather than
+	// serving a 500. Useful in tests. Only the root node's value is used.
+	Repanic bool
+	// CacheControl is sent with successful GET and HEAD responses from this
+	// node. It is set from the cache() tag of the field the node was built
+	// for, e.g. `halgo:"embed() cache(max-age=60, public)"`.
+	CacheControl string
+	// RequirePreconditions makes PUT, PATCH and DELETE on this node fail with
+	// 428 Precondition Required unless they send If-Match or If-None-Match.
+	// It is set by the preconditions(required) tag of the field the node was
+	// built for.
+	RequirePreconditions bool
+	// PostedType is the type of the body the entity's Process method
+	// accepts, or nil if it has none.
+	PostedType reflect.Type
 }
 
 type HttpNode interface {
@@ -346,17 +745,53 @@ func (n *Node) SupportsDELETE() bool {
 }
 
 func (n *Node) SupportsPATCH() bool {
-	return false
+	return n.Methods.Manifest != nil && n.Methods.Write != nil
 }
 
 func (n *Node) SupportsPOST() bool {
-	return false
+	return n.Methods.Process != nil
 }
 
 type Child struct {
 	Node *Node
 	Meta meta
 	Kind reflect.Kind
+	// Name is the name of the Go field this child was declared as.
+	Name string
+	// Property is the JSON property name of that field in the parent entity.
+	Property string
+	// Page is set for collection children whose type implements Page.
+	Page Page_C
+	// Query is set for collection children whose type implements Query.
+	Query Query_C
+}
+
+// Rel returns the link relation for this child, which is the rel declared by
+// its link(rel=...) tag if there is one, or else its lower-cased field name.
+func (c *Child) Rel() string {
+	if c.Meta.child_link_rel != nil {
+		return *c.Meta.child_link_rel
+	}
+	return strings.ToLower(c.Name)
+}
+
+// Child returns the child with the given link relation.
+func (n *Node) Child(rel string) (*Child, bool) {
+	if n.ID_Child != nil {
+		return n.ID_Child, n.ID_Child.Rel() == rel
+	}
+	for _, c := range *n.Children {
+		if c.Rel() == rel {
+			return c, true
+		}
+	}
+	return nil, false
+}
+
+// Var returns the URI template variable name used to address members of a
+// collection child, e.g. "app" for a collection of App.
+func (c *Child) Var() string {
+	return strings.ToLower(c.Node.EntityType.Name())
 }
 
 func (n *Node) AssertParentType(methodName string, parent reflect.Type) error {
@@ -388,27 +823,27 @@ type compiled_methods struct {
 
 // Exists may be specified to optimise the situation where manifesting a resource
 // is more expensive than simply asserting that it exists.
-// Params: parent, id, self, input
-type Exists_C func(interface{}, string) (bool, error)
+// Params: request, parent, id, self, input
+type Exists_C func(*Request, interface{}, string) (bool, error)
 
 // Manifest == GET, also used for HEAD
-// Params: parent, id
-type Manifest_C func(interface{}, string) (interface{}, error)
+// Params: request, parent, id
+type Manifest_C func(*Request, interface{}, string) (interface{}, error)
 
-// Params: parent, id, self
-type Validate_C func(interface{}, string, interface{}) error
+// Params: request, parent, id, self
+type Validate_C func(*Request, interface{}, string, interface{}) error
 
 // Write is called for both PUT and POST (POSTs are converted to PUT-like operations internally)
-// Params: parent, id, self
-type Write_C func(interface{}, string, interface{}) error
+// Params: request, parent, id, self
+type Write_C func(*Request, interface{}, string, interface{}) error
 
 // Guess which HTTP method Delete corresponds with...
-// Params: parent, id, self
-type Delete_C func(interface{}, string, interface{}) error
+// Params: request, parent, id, self
+type Delete_C func(*Request, interface{}, string, interface{}) error
 
 // Process == POST
-// Params: parent, id, self, input
-type Process_C func(interface{}, string, interface{}, interface{}) (interface{}, error)
+// Params: request, parent, id, posted (decodes the request body into the given type)
+type Process_C func(*Request, interface{}, string, func(reflect.Type) (interface{}, error)) (interface{}, error)
 
 //
 // User method specs
@@ -432,46 +867,44 @@ type Delete_U func(interface{}, string) error
 type Process_U func(interface{}, string, interface{}) (interface{}, error)
 
 func makeExists(s StandardMethod) Exists_C {
-	return func(parent interface{}, id string) (bool, error) {
-		out := s(&standardInputs{Parent: parent, ID: id})
+	return func(r *Request, parent interface{}, id string) (bool, error) {
+		out := s(&standardInputs{Request: r, Parent: parent, ID: id})
 		return *out.TrueOrFalse, out.Error
 	}
 }
 
 func makeManifest(s StandardMethod) Manifest_C {
-	return func(parent interface{}, id string) (interface{}, error) {
-		out := s(&standardInputs{Parent: parent, ID: id})
+	return func(r *Request, parent interface{}, id string) (interface{}, error) {
+		out := s(&standardInputs{Request: r, Parent: parent, ID: id})
 		return out.Self, out.Error
 	}
 }
 
 func makeValidate(s StandardMethod) Validate_C {
-	return func(parent interface{}, id string, self interface{}) error {
-		out := s(&standardInputs{Self: self, Parent: parent, ID: id})
+	return func(r *Request, parent interface{}, id string, self interface{}) error {
+		out := s(&standardInputs{Request: r, Self: self, Parent: parent, ID: id})
 		return out.Error
 	}
 }
 
 func makeWrite(s StandardMethod) Write_C {
-	return func(parent interface{}, id string, self interface{}) error {
-		out := s(&standardInputs{Self: self, Parent: parent, ID: id})
+	return func(r *Request, parent interface{}, id string, self interface{}) error {
+		out := s(&standardInputs{Request: r, Self: self, Parent: parent, ID: id})
 		return out.Error
 	}
 }
 
 func makeDelete(s StandardMethod) Delete_C {
-	return func(parent interface{}, id string, self interface{}) error {
-		out := s(&standardInputs{Self: self, Parent: parent, ID: id})
+	return func(r *Request, parent interface{}, id string, self interface{}) error {
+		out := s(&standardInputs{Request: r, Self: self, Parent: parent, ID: id})
 		return out.Error
 	}
 }
 
 func makeProcess(s StandardMethod) Process_C {
-	return func(parent interface{}, id string, self interface{}, otherIn interface{}) (otherOut interface{}, err error) {
-		//_, _, otherOut, err = s(self, parent, id, otherIn)
-		// TODO: Implement this
-		panic("Process not implemented by the framework.")
-		return otherOut, err
+	return func(r *Request, parent interface{}, id string, posted func(reflect.Type) (interface{}, error)) (interface{}, error) {
+		out := s(&standardInputs{Request: r, Parent: parent, ID: id, Posted: posted})
+		return out.OtherEntity, out.Error
 	}
 }
 
@@ -508,10 +941,14 @@ type standardised_methods struct {
 func (n *Node) CompileMethods() error {
 	compiled := reflect.ValueOf(&compiled_methods{})
 	numCompiled := compiled.Elem().NumField()
+	errs := errorList{}
 	for i := 0; i < numCompiled; i++ {
 		name := compiled_methods_T.Field(i).Name
 		if s, err := n.CompileMethod(name); err != nil {
-			return err
+			errs = append(errs, err)
+		} else if s == nil {
+			// The user type does not implement this method.
+			continue
 		} else {
 			standard := standardToCompiledMethod(name, s)
 			compiled.Elem().FieldByName(name).Set(reflect.ValueOf(standard))
@@ -521,7 +958,10 @@ func (n *Node) CompileMethods() error {
 	n.Methods = *(compiled.Interface().(*compiled_methods))
 	// Validate method set
 	if n.Methods.Manifest == nil {
-		return Error("*"+fmt.Sprint(n.EntityType), " does not have a Manifest method")
+		errs = append(errs, Error("*"+fmt.Sprint(n.EntityType), " does not have a Manifest method"))
+	}
+	if len(errs) != 0 {
+		return errs
 	}
 	// Apply patched exists method if none provided
 	if n.Methods.Exists == nil {
@@ -537,13 +977,23 @@ func (n *Node) CompileMethods() error {
 	return nil
 }
 
+// convertManifestToExists makes an Exists from a Manifest, which reports that
+// its entity does not exist by returning a 404 error, e.g. Error404.
 func convertManifestToExists(m Manifest_C) Exists_C {
-	return func(parent interface{}, id string) (bool, error) {
-		entity, err := m(parent, id)
+	return func(r *Request, parent interface{}, id string) (bool, error) {
+		entity, err := m(r, parent, id)
+		if isNotFound(err) {
+			return false, nil
+		}
 		return entity != nil, err
 	}
 }
 
+// isNotFound reports whether err says that an entity does not exist.
+func isNotFound(err error) bool {
+	return errorStatus(err) == 404
+}
+
 func (n *Node) CompileMethod(name string) (StandardMethod, error) {
 	if compiledMethod_F, ok := user_methods_T.FieldByName(name); !ok {
 		panic("Compiled methods does not have a member named " + name)
@@ -566,10 +1016,11 @@ func (n *Node) CompileMethod(name string) (StandardMethod, error) {
 type StandardMethod func(*standardInputs) *standardOutputs
 
 type standardInputs struct {
-	Self   interface{}
-	Parent interface{}
-	ID     string
-	Posted func(reflect.Type) (interface{}, error)
+	Request *Request
+	Self    interface{}
+	Parent  interface{}
+	ID      string
+	Posted  func(reflect.Type) (interface{}, error)
 }
 
 type standardOutputs struct {
@@ -594,26 +1045,40 @@ func (n *Node) makeStandardMethod(name string, inMaker *inputMaker, outMaker *ou
 	}
 }
 
+type input int
+
+const (
+	parent_input input = iota
+	id_input
+	posted_input
+	context_input
+	request_input
+)
+
+// inputMaker knows which input goes in each of a user method's parameters.
 type inputMaker struct {
-	ParentRequired     bool
-	IdRequired         bool
-	PostedBodyRequired bool
-	PostedBodyType     reflect.Type
+	Inputs         []input
+	PostedBodyType reflect.Type
 }
 
 func (im *inputMaker) makeInputs(in *standardInputs) ([]reflect.Value, error) {
-	inputs := []reflect.Value{}
-	if im.ParentRequired {
-		inputs = append(inputs, reflect.ValueOf(in.Parent))
-	}
-	if im.IdRequired {
-		inputs = append(inputs, reflect.ValueOf(in.ID))
-	}
-	if im.PostedBodyRequired {
-		if body, err := in.Posted(im.PostedBodyType); err != nil {
-			return nil, err
-		} else {
-			inputs = append(inputs, reflect.ValueOf(body))
+	inputs := make([]reflect.Value, len(im.Inputs))
+	for i, kind := range im.Inputs {
+		switch kind {
+		case parent_input:
+			inputs[i] = reflect.ValueOf(in.Parent)
+		case id_input:
+			inputs[i] = reflect.ValueOf(in.ID)
+		case posted_input:
+			if body, err := in.Posted(im.PostedBodyType); err != nil {
+				return nil, err
+			} else {
+				inputs[i] = reflect.ValueOf(body)
+			}
+		case context_input:
+			inputs[i] = reflect.ValueOf(in.Request.context())
+		case request_input:
+			inputs[i] = reflect.ValueOf(in.Request)
 		}
 	}
 	return inputs, nil
@@ -628,7 +1093,35 @@ func (n *Node) analyseInputs(methodName string, compiledMethod_T reflect.Type, u
 
 	// Skip the first input, it's the receiver (the entity itself)
 	actualIn = actualIn[1:]
-	actualNumIn = len(actualIn)
+	im.Inputs = make([]input, len(actualIn))
+
+	// context.Context and *Request may be accepted in any position. The
+	// remaining parameters are identified by their order.
+	positional := []int{}
+	for i, actualT := range actualIn {
+		if actualT == context_T {
+			im.Inputs[i] = context_input
+		} else if actualT == request_T {
+			im.Inputs[i] = request_input
+		} else {
+			positional = append(positional, i)
+		}
+	}
+	actualNumIn = len(positional)
+
+	// Process always takes the posted body as its last parameter, whether or
+	// not it also takes the parent and id.
+	if methodName == "Process" {
+		if actualNumIn == 0 {
+			return nil, n.methodError(methodName, "must accept the posted body as its last parameter.")
+		}
+		last := positional[actualNumIn-1]
+		im.Inputs[last] = posted_input
+		im.PostedBodyType = actualIn[last]
+		n.PostedType = im.PostedBodyType
+		positional = positional[:actualNumIn-1]
+		actualNumIn = len(positional)
+	}
 
 	// Validate Inputs
 	if actualNumIn > specMaxIn {
@@ -639,20 +1132,19 @@ func (n *Node) analyseInputs(methodName string, compiledMethod_T reflect.Type, u
 			return nil, err
 		}
 	}
-	for i, actualT := range actualIn {
-		if i == 0 {
-			im.ParentRequired = true
-			if err := n.AssertParentType(methodName, actualT); err != nil {
+	for j, i := range positional {
+		if j == 0 {
+			im.Inputs[i] = parent_input
+			if err := n.AssertParentType(methodName, actualIn[i]); err != nil {
 				return nil, err
 			}
-		} else if i == 1 {
-			im.IdRequired = true
+		} else if j == 1 {
+			im.Inputs[i] = id_input
 			if err := n.AssertIdentity(true); err != nil {
 				return nil, err
 			}
-		} else if i == 2 {
-			im.PostedBodyRequired = true
-			im.PostedBodyType = actualT
+		} else {
+			return nil, n.methodError(methodName, "has too many parameters.")
 		}
 	}
 	return im, nil
@@ -0,0 +1,150 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func Test_ServeHTTP_HALDocument(t *testing.T) {
+	w := serve(t, "GET", "/", "")
+	if ct := w.Header().Get("Content-Type"); w.Code != 200 || ct != "application/hal+json" {
+		t.Fatalf("Expected 200 application/hal+json but got %v %v", w.Code, ct)
+	}
+	doc := halBody(t, w)
+	if doc["welcome"] != "Welcome to the deployment service API" || doc["version"] != "0.0.110" {
+		t.Errorf("Expected the entity's properties at the top level but got %v", doc)
+	}
+	if _, ok := doc["apps"]; ok {
+		t.Errorf("Expected the apps child field to be removed but got %v", doc["apps"])
+	}
+	if self := lookup(doc, "_links", "self", "href"); self != "/" {
+		t.Errorf("Expected a self link to / but got %v", self)
+	}
+	apps, ok := lookup(doc, "_embedded", "apps").(map[string]interface{})
+	if !ok || apps["numberOfApps"] != 1.0 || lookup(apps, "_links", "self", "href") != "/apps" {
+		t.Errorf("Expected apps embedded under its rel, with its own self link, but got %v", doc["_embedded"])
+	}
+	keys := sortedKeys(doc)
+	if expected := []string{"_embedded", "_links", "version", "welcome"}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("Expected only the members %v but got %v", expected, keys)
+	}
+	doc = halBody(t, serve(t, "GET", "/apps/test-app", ""))
+	if _, ok := doc["versions"]; ok || doc["name"] != "test-app" || lookup(doc, "_embedded", "versions") == nil {
+		t.Errorf("Expected name at the top level and versions only under _embedded but got %v", doc)
+	}
+}
+
+func Test_ServeHTTP_EmbedsCollections(t *testing.T) {
+	doc := halBody(t, serve(t, "GET", "/apps/test-app", ""))
+	versions, _ := lookup(doc, "_embedded", "versions").([]interface{})
+	expected := []string{"/apps/test-app/0.2.0", "/apps/test-app/1.2.3", "/apps/test-app/1.3.9"}
+	hrefs := []string{}
+	for _, v := range versions {
+		href, _ := lookup(v, "_links", "self", "href").(string)
+		hrefs = append(hrefs, href)
+	}
+	if !reflect.DeepEqual(hrefs, expected) {
+		t.Errorf("Expected versions embedded with self links %v but got %v", expected, hrefs)
+	}
+	if lookup(versions, "1", "version") != "1.2.3" || lookup(versions, "1", "id") != "test-app-1-2-3" {
+		t.Errorf("Expected each version embedded in full but got %v", lookup(versions, "1"))
+	}
+}
+
+type Garage struct {
+	Cars  []Car `json:"cars"  halgo:"embed(fields=make)"`
+	Owner string
+}
+
+func (g *Garage) Manifest() error {
+	*g = Garage{Cars: []Car{{"Fiat", "Panda"}, {"Saab", "900"}}, Owner: "sam"}
+	return nil
+}
+
+type Car struct {
+	Make  string `json:"make"`
+	Model string `json:"model"`
+}
+
+func (c *Car) Manifest(parent *Garage, id string) error {
+	if i, err := strconv.Atoi(id); err != nil || i < 0 || i >= len(parent.Cars) {
+		return Error404(id)
+	} else {
+		*c = parent.Cars[i]
+	}
+	return nil
+}
+
+type Depot struct {
+	Name string
+	Vans []Van `json:"vans" halgo:"embed(href)"`
+}
+
+func (d *Depot) Manifest() error {
+	*d = Depot{Name: "depot", Vans: []Van{{"a"}, {"b"}}}
+	return nil
+}
+
+type Van struct {
+	Plate string `json:"plate"`
+}
+
+func (v *Van) Manifest(parent *Depot, id string) error {
+	if i, err := strconv.Atoi(id); err != nil || i < 0 || i >= len(parent.Vans) {
+		return Error404(id)
+	} else {
+		*v = parent.Vans[i]
+	}
+	return nil
+}
+
+func Test_ServeHTTP_EmbedsCollectionItemsAsDeclared(t *testing.T) {
+	cases := []struct {
+		root     interface{}
+		rel      string
+		expected []interface{}
+	}{
+		{Garage{}, "cars", []interface{}{
+			map[string]interface{}{"make": "Fiat", "_links": map[string]interface{}{"self": map[string]interface{}{"href": "/0"}}},
+			map[string]interface{}{"make": "Saab", "_links": map[string]interface{}{"self": map[string]interface{}{"href": "/1"}}},
+		}},
+		{Depot{}, "vans", []interface{}{
+			map[string]interface{}{"_links": map[string]interface{}{"self": map[string]interface{}{"href": "/0"}}},
+			map[string]interface{}{"_links": map[string]interface{}{"self": map[string]interface{}{"href": "/1"}}},
+		}},
+	}
+	for _, c := range cases {
+		g, err := Graph(c.root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if items := lookup(halBody(t, w), "_embedded", c.rel); !reflect.DeepEqual(items, c.expected) {
+			t.Errorf("Expected %v embedded as\n\t%v\nbut got\n\t%v", c.rel, c.expected, items)
+		}
+	}
+}
+
+type Counter struct {
+	Count int `json:"count"`
+}
+
+// Manifest leaves the counter at zero, which is a perfectly good count.
+func (c *Counter) Manifest() error {
+	return nil
+}
+
+func Test_ServeHTTP_ZeroValuedEntity(t *testing.T) {
+	g, err := Graph(Counter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if doc := halBody(t, w); w.Code != 200 || doc["count"] != 0.0 {
+		t.Errorf("Expected 200 with count 0 but got %v %s", w.Code, w.Body)
+	}
+}
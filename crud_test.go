@@ -25,7 +25,9 @@ func (entity *Apps) Manifest() error {
 }
 
 func (entity *App) Manifest(parent *Apps, id string) error {
-	if app, ok := parent.Apps[id]; ok {
+	if app, ok := parent.Apps[id]; !ok {
+		return Error404(id)
+	} else {
 		(*entity) = app
 	}
 	return nil
@@ -33,10 +35,12 @@ func (entity *App) Manifest(parent *Apps, id string) error {
 
 // Manifest should try to find and load the entity. If any parents are missing,
 // it should return a 404 on that parent. If the parents are there, but this
-// item is not, it should leave a as nil. Otherwise it should populate a with
-// the stored entity.
+// item is not, it should return a 404 for the item, e.g. with Error404.
+// Otherwise it should populate a with the stored entity.
 func (a *AppVersion) Manifest(parent *App, id string) error {
-	if ver, ok := parent.Versions[id]; ok {
+	if ver, ok := parent.Versions[id]; !ok {
+		return Error404(parent.Name + " v" + id)
+	} else {
 		(*a) = ver
 	}
 	return nil
@@ -49,14 +53,26 @@ func (a *AppVersion) Write(parent *App, id string) error {
 	return nil
 }
 
+// Validate rejects versions whose body disagrees with the URL. Returning a
+// ValidationError results in a 422; any other error is a 500.
 func (a *AppVersion) Validate(parent *App, id string) error {
-	// Anything this returns will be interpreted as a validation error (e.g. 400/409/4xx?)
-	return nil
+	var errs ValidationError
+	if a.Version != id {
+		errs.Add("/version", "must match the version in the URL, ", id)
+	}
+	if a.Name != parent.Name {
+		errs.Add("/name", "must match the app name, ", parent.Name)
+	}
+	return errs.Err()
 }
 
-func (a *AppVersion) Process(parent *App, payload interface{}) (interface{}, error) {
-	// This would be the POST handler
-	return nil, Error("Process not implemented.")
+// Process is the POST handler, here accepting a request to deploy the version.
+func (a *AppVersion) Process(parent *App, id string, d *Deployment) (interface{}, error) {
+	if _, ok := parent.Versions[id]; !ok {
+		return nil, Error404(parent.Name + " v" + id)
+	}
+	d.Version = id
+	return Processed{StatusCode: 202, Entity: d}, nil
 }
 
 func (a *AppVersion) Delete(parent *App, id string) error {
@@ -2,6 +2,9 @@ package halgo
 
 import (
 	"fmt"
+	"path"
+	"reflect"
+	"strings"
 )
 
 type HalgoError struct {
@@ -18,11 +21,22 @@ func Error(args ...interface{}) HalgoError {
 	return HalgoError{message}
 }
 
+// StatusCoder is implemented by errors which should be served with a specific
+// HTTP status code. Errors wrapping a StatusCoder (see errors.As) are served
+// with the wrapped error's status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
 type HTTPError struct {
-	StatusCode int
+	Code int
 	HalgoError
 }
 
+func (err HTTPError) StatusCode() int {
+	return err.Code
+}
+
 func HttpError(statusCode int, args ...interface{}) HTTPError {
 	return HTTPError{statusCode, Error(args...)}
 }
@@ -39,6 +53,50 @@ func Error409(message string) HTTPError {
 	return HttpError(409, message)
 }
 
+// ValidationError is returned from Validate methods to reject an entity. It is
+// served as 422 Unprocessable Entity, listing each invalid field.
+type ValidationError struct {
+	Fields []FieldError `json:"errors"`
+}
+
+// FieldError describes a single invalid field. Pointer is the JSON pointer
+// (RFC 6901) to the field in the request document, e.g. "/version".
+type FieldError struct {
+	Pointer string `json:"pointer"`
+	Message string `json:"message"`
+}
+
+func (err ValidationError) StatusCode() int {
+	return 422
+}
+
+func (err ValidationError) Error() string {
+	messages := make([]string, len(err.Fields))
+	for i, f := range err.Fields {
+		messages[i] = f.Pointer + ": " + f.Message
+	}
+	return "Validation failed: " + strings.Join(messages, "; ")
+}
+
+// Invalid returns a ValidationError with a single field error.
+func Invalid(pointer string, args ...interface{}) ValidationError {
+	return ValidationError{[]FieldError{{pointer, fmt.Sprint(args...)}}}
+}
+
+// Add records another invalid field.
+func (err *ValidationError) Add(pointer string, args ...interface{}) {
+	err.Fields = append(err.Fields, FieldError{pointer, fmt.Sprint(args...)})
+}
+
+// Err returns nil if no fields have been added, so that a ValidationError
+// built up with Add can be returned directly from Validate.
+func (err ValidationError) Err() error {
+	if len(err.Fields) == 0 {
+		return nil
+	}
+	return err
+}
+
 func Errorf(format string, args ...interface{}) error {
 	return Error(fmt.Sprintf(format, args...))
 }
@@ -46,3 +104,70 @@ func Errorf(format string, args ...interface{}) error {
 func Print(args ...interface{}) {
 	fmt.Println(args)
 }
+
+// GraphError is a problem with one part of the type tree passed to Graph.
+type GraphError struct {
+	// Route is the URL path template of the node, e.g. "/apps/{app}".
+	Route string
+	// Field is the path through the Go types to the node or field, e.g.
+	// "RootResource.Apps.Apps[*].Versions".
+	Field string
+	Err   error
+}
+
+func (err GraphError) Error() string {
+	return err.Field + " (" + err.Route + "): " + err.Err.Error()
+}
+
+func (err GraphError) Unwrap() error {
+	return err.Err
+}
+
+// GraphErrors lists every problem found while building a graph.
+type GraphErrors []GraphError
+
+func (errs GraphErrors) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = "\n\t" + err.Error()
+	}
+	return fmt.Sprint(len(errs), " problem(s) building graph:", strings.Join(messages, ""))
+}
+
+func (errs *GraphErrors) add(at graphPath, err error) {
+	if list, ok := err.(errorList); ok {
+		for _, e := range list {
+			errs.add(at, e)
+		}
+	} else {
+		*errs = append(*errs, GraphError{at.route, at.field, err})
+	}
+}
+
+// errorList is several independent errors, e.g. one per method of a type.
+type errorList []error
+
+func (errs errorList) Error() string {
+	messages := make([]string, len(errs))
+	for i, err := range errs {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// graphPath locates a node while building a graph.
+type graphPath struct {
+	route string
+	field string
+}
+
+// child returns the location of field f itself, and of the node built for it.
+func (at graphPath) child(f reflect.StructField) (graphPath, graphPath) {
+	field := at.field + "." + f.Name
+	if k := f.Type.Kind(); k == reflect.Map || k == reflect.Slice {
+		route := path.Join(at.route, "{"+strings.ToLower(entityType(f.Type).Name())+"}")
+		return graphPath{route, field}, graphPath{route, field + "[*]"}
+	}
+	route := path.Join(at.route, strings.ToLower(f.Name))
+	return graphPath{route, field}, graphPath{route, field}
+}
@@ -0,0 +1,57 @@
+package halgo
+
+import (
+	"testing"
+)
+
+func Test_ValidationError(t *testing.T) {
+	var errs ValidationError
+	error_should_be_nil(t, errs.Err())
+	errs.Add("/version", "must match ", "1.4.0")
+	errs.Add("/name", "is required")
+	error_should_contain(t, errs.Err(), "/version: must match 1.4.0; /name: is required")
+}
+
+type BrokenRoot struct {
+	Shelves BrokenShelves `json:"shelves" halgo:"embed()"`
+	Health  *Health       `json:"health"  halgo:"embed(sometimes)"`
+}
+
+func (r *BrokenRoot) Manifest() error { return nil }
+
+type BrokenShelves map[string]BrokenShelf
+
+type BrokenShelf struct {
+	Books []BrokenBook `json:"books" halgo:"embed()"`
+	Pens  []BrokenBook `json:"pens"  halgo:"embed()"`
+}
+
+type BrokenBook struct {
+	Title string `json:"title" validate:"pattern=("`
+}
+
+func (b *BrokenBook) Manifest(parent *BrokenShelf, id string) error { return nil }
+
+func Test_Graph_ReportsAllErrors(t *testing.T) {
+	_, err := Graph(BrokenRoot{})
+	errs, ok := err.(GraphErrors)
+	if !ok {
+		t.Fatalf("Expected GraphErrors but got %v", err)
+	}
+	expected := []struct{ route, field, message string }{
+		{"/{brokenshelf}", "BrokenRoot.Shelves[*]", "does not have a Manifest method"},
+		{"/{brokenshelf}/{brokenbook}", "BrokenRoot.Shelves[*].Books[*]", "invalid pattern"},
+		{"/{brokenshelf}/{brokenbook}", "BrokenRoot.Shelves[*].Pens[*]", "invalid pattern"},
+		{"/{brokenshelf}", "BrokenRoot.Shelves[*]", "contains more than one collection child"},
+		{"/health", "BrokenRoot.Health", "is not recognised"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("Expected %v errors but got %v", len(expected), err)
+	}
+	for i, e := range expected {
+		if errs[i].Route != e.route || errs[i].Field != e.field {
+			t.Errorf("Expected error %v at %s (%s) but got %s (%s)", i, e.field, e.route, errs[i].Field, errs[i].Route)
+		}
+		error_should_contain(t, errs[i], e.message)
+	}
+}
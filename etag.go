@@ -0,0 +1,109 @@
+package halgo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+// Versioned may be implemented by entities which know their own version, e.g.
+// a revision number from storage. The ETag of a Versioned entity is derived
+// from its version rather than by hashing the rendered document, so Version
+// must change whenever anything in the representation changes, including
+// embedded children.
+type Versioned interface {
+	Version() string
+}
+
+// strongETag derives a strong entity tag for a representation in mediaType.
+// Different representations of the same resource get different tags, as
+// required of strong validators.
+func strongETag(mediaType, kind string, data []byte) string {
+	h := sha256.New()
+	h.Write([]byte(mediaType + "\x00" + kind + "\x00"))
+	h.Write(data)
+	return `"` + hex.EncodeToString(h.Sum(nil)[:16]) + `"`
+}
+
+// resourceETag returns the ETag of res as rendered by renderer, along with the
+// rendered body if it had to be rendered to derive the tag. The ETag of a
+// Versioned resource also depends on the view parameters, e.g. ?fields=, that
+// shaped it.
+func resourceETag(renderer Renderer, res *Resource) (string, []byte, error) {
+	if res.Version != "" {
+		return strongETag(renderer.MediaType(), "version", []byte(res.Version+"\x00"+res.view)), nil, nil
+	} else if body, err := renderer.Render(res); err != nil {
+		return "", nil, err
+	} else {
+		return strongETag(renderer.MediaType(), "body", body), body, nil
+	}
+}
+
+// checkPreconditions evaluates If-Match and If-None-Match for a request which
+// would change the entity at n, against the ETags a GET would currently emit
+// for it in any media type.
+func (root *Node) checkPreconditions(r *Request, n *Node, in *StandardHTTPMethodInputs) error {
+	ifMatch, ifNoneMatch := r.Header.Get("If-Match"), r.Header.Get("If-None-Match")
+	if ifMatch == "" && ifNoneMatch == "" {
+		if n.RequirePreconditions {
+			return HttpError(428, r.Method, " ", r.URL.Path, " requires an If-Match or If-None-Match header.")
+		}
+		return nil
+	}
+	current, err := n.Methods.Manifest(r, in.Parent, in.ID)
+	if isNotFound(err) {
+		current = nil
+	} else if err != nil {
+		return err
+	}
+	var etags []string
+	if current != nil {
+		if etags, err = root.currentETags(r, n, current); err != nil {
+			return err
+		}
+	}
+	if ifMatch != "" && !anyETagMatches(ifMatch, current != nil, etags, false) {
+		return HttpError(412, "If-Match: ", ifMatch, " does not match the current entity.")
+	} else if ifNoneMatch != "" && anyETagMatches(ifNoneMatch, current != nil, etags, true) {
+		return HttpError(412, "If-None-Match: ", ifNoneMatch, " matches the current entity.")
+	}
+	return nil
+}
+
+func (root *Node) currentETags(r *Request, n *Node, entity interface{}) ([]string, error) {
+	v, err := n.parseView(r, root.MaxEmbedDepth)
+	if err != nil {
+		return nil, err
+	}
+	res, err := n.makeResource(entity, r.URL.Path, v)
+	if err != nil {
+		return nil, err
+	}
+	etags := make([]string, len(root.Renderers))
+	for i, renderer := range root.Renderers {
+		if etags[i], _, err = resourceETag(renderer, res); err != nil {
+			return nil, err
+		}
+	}
+	return etags, nil
+}
+
+// anyETagMatches reports whether a precondition header matches any of etags.
+// "*" matches whenever the entity exists. If-Match uses strong comparison, so
+// weak tags never match it; If-None-Match uses weak comparison.
+func anyETagMatches(header string, exists bool, etags []string, weak bool) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" {
+			return exists
+		} else if weak {
+			t = strings.TrimPrefix(t, "W/")
+		}
+		for _, etag := range etags {
+			if t == etag {
+				return true
+			}
+		}
+	}
+	return false
+}
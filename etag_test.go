@@ -0,0 +1,179 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_anyETagMatches(t *testing.T) {
+	cases := []struct {
+		header   string
+		exists   bool
+		weak     bool
+		expected bool
+	}{
+		{``, true, true, false},
+		{`"abc"`, true, false, true},
+		{`W/"abc"`, true, true, true},
+		{`W/"abc"`, true, false, false},
+		{`"xyz", "abc"`, true, false, true},
+		{`"xyz"`, true, true, false},
+		{`*`, true, false, true},
+		{`*`, false, false, false},
+	}
+	for _, c := range cases {
+		if actual := anyETagMatches(c.header, c.exists, []string{`"abc"`}, c.weak); actual != c.expected {
+			t.Errorf("%s (exists %v, weak %v): expected %v but got %v", c.header, c.exists, c.weak, c.expected, actual)
+		}
+	}
+}
+
+func Test_ServeHTTP_ConditionalGET(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/apps/test-app", nil))
+	etag := w.Header().Get("ETag")
+	if w.Code != 200 || etag == "" {
+		t.Fatalf("Expected 200 with an ETag but got %v %v", w.Code, w.Header())
+	}
+
+	r := httptest.NewRequest("GET", "/apps/test-app", nil)
+	r.Header.Set("If-None-Match", etag)
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != 304 || w.Body.Len() != 0 || w.Header().Get("ETag") != etag {
+		t.Errorf("Expected an empty 304 with ETag %s but got %v %v %q", etag, w.Code, w.Header(), w.Body)
+	}
+
+	r.Header.Set("Accept", "application/json")
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, r)
+	if w.Code != 200 || w.Header().Get("ETag") == etag {
+		t.Errorf("Expected a 200 with a different ETag for another media type, but got %v %v", w.Code, w.Header())
+	}
+}
+
+type Ledger struct {
+	Balance int `json:"balance"`
+}
+
+func (l *Ledger) Manifest() error {
+	l.Balance = 10
+	return nil
+}
+
+func (l *Ledger) Version() string {
+	return "rev-7"
+}
+
+func Test_ServeHTTP_VersionedETag(t *testing.T) {
+	g, err := Graph(Ledger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	expected := strongETag(HALJSONRenderer{}.MediaType(), "version", []byte("rev-7\x00"))
+	if actual := w.Header().Get("ETag"); actual != expected {
+		t.Errorf("Expected ETag %s but got %s", expected, actual)
+	}
+	projected := httptest.NewRecorder()
+	g.ServeHTTP(projected, httptest.NewRequest("GET", "/?fields=balance&unrelated=1", nil))
+	expected = strongETag(HALJSONRenderer{}.MediaType(), "version", []byte("rev-7\x00fields=balance"))
+	if actual := projected.Header().Get("ETag"); actual != expected {
+		t.Errorf("Expected ETag %s for ?fields=balance but got %s", expected, actual)
+	}
+}
+
+func Test_ServeHTTP_Preconditions(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer delete(the_apps["test-app"].Versions, "1.6.0")
+	put := func(headers ...string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("PUT", "/apps/test-app/1.6.0", strings.NewReader(
+			`{"id": "test-app-1-6-0", "name": "test-app", "version": "1.6.0"}`))
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		return w
+	}
+	if w := put("If-Match", "*"); w.Code != 412 {
+		t.Errorf("Expected 412 for If-Match: * on a missing entity but got %v", w.Code)
+	}
+	if w := put("If-None-Match", "*"); w.Code != 201 {
+		t.Errorf("Expected 201 for If-None-Match: * on a missing entity but got %v", w.Code)
+	}
+	if w := put("If-None-Match", "*"); w.Code != 412 {
+		t.Errorf("Expected 412 for If-None-Match: * on an existing entity but got %v", w.Code)
+	}
+	if w := put("If-Match", `"stale"`); w.Code != 412 {
+		t.Errorf("Expected 412 for a stale If-Match but got %v", w.Code)
+	}
+
+	get := httptest.NewRecorder()
+	g.ServeHTTP(get, httptest.NewRequest("GET", "/apps/test-app/1.6.0", nil))
+	if w := put("If-Match", get.Header().Get("ETag")); w.Code != 200 {
+		t.Errorf("Expected 200 for a current If-Match but got %v", w.Code)
+	}
+}
+
+type Locker struct {
+	Name  string          `json:"name"`
+	Items map[string]Item `json:"items" halgo:"embed() preconditions(required)"`
+}
+
+var the_items = map[string]Item{}
+
+func (l *Locker) Manifest() error {
+	*l = Locker{Name: "locker", Items: the_items}
+	return nil
+}
+
+type Item struct {
+	Label string `json:"label"`
+}
+
+func (i *Item) Manifest(parent *Locker, id string) error {
+	if item, ok := parent.Items[id]; !ok {
+		return Error404(id)
+	} else {
+		*i = item
+	}
+	return nil
+}
+
+func (i *Item) Write(parent *Locker, id string) error {
+	parent.Items[id] = *i
+	return nil
+}
+
+func Test_ServeHTTP_RequirePreconditions(t *testing.T) {
+	g, err := Graph(Locker{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer delete(the_items, "key")
+	put := func(headers ...string) int {
+		r := httptest.NewRequest("PUT", "/key", strings.NewReader(`{"label": "key"}`))
+		for i := 0; i+1 < len(headers); i += 2 {
+			r.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, r)
+		return w.Code
+	}
+	if code := put(); code != 428 {
+		t.Errorf("Expected 428 without preconditions but got %v", code)
+	}
+	if code := put("If-None-Match", "*"); code != 201 {
+		t.Errorf("Expected 201 for If-None-Match: * but got %v", code)
+	}
+}
@@ -0,0 +1,326 @@
+package halgo
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Query is a filter and sort order on the members of a collection, parsed
+// from ?filter= and ?sort= on a GET of the collection's parent.
+//
+// The filter grammar is one or more comparisons joined by "and":
+//
+//	filter     = comparison *( " and " comparison )
+//	comparison = property " " op " " value
+//	op         = "eq" / "ne" / "lt" / "le" / "gt" / "ge"
+//	value      = "'" string "'" / number / "true" / "false" / "null"
+//
+// Quotes inside strings are escaped by doubling them, e.g. 'it''s'. Sort is a
+// comma separated list of properties, each prefixed by "-" for descending
+// order. E.g. ?filter=name eq 'test-app'&sort=-version
+//
+// Properties are JSON property names of the collection's member type.
+//
+// Collections are filtered and sorted in memory. A collection type may do this
+// itself, e.g. in its database, by implementing:
+//
+//	func (l *AppsList) Query(parent *Apps, q halgo.Query) error
+//
+// populating l with all the matching members, in order. Query is then called
+// instead of reading the parent's field whenever a filter or sort is
+// requested.
+//
+// Collections which implement Page must also implement Query to be filtered
+// or sorted, as filtering a single page would miss members. Their Query pages
+// its results itself, so that every ?cursor= of the collection comes from the
+// same scheme whether or not a filter is applied:
+//
+//	func (l *AppsList) Query(parent *Apps, q halgo.Query) (halgo.Cursors, error)
+//
+// populating l with at most q.Limit matching members starting at q.Cursor.
+type Query struct {
+	Filter []Condition
+	Sort   []SortKey
+	// Cursor and Limit are the page wanted, for collections which implement
+	// Page. They are zero otherwise.
+	Cursor string
+	Limit  int
+}
+
+type Condition struct {
+	Property string
+	Op       string
+	// Value is a string, float64, bool or nil.
+	Value interface{}
+}
+
+type SortKey struct {
+	Property   string
+	Descending bool
+}
+
+const (
+	FILTER = "filter"
+	SORT   = "sort"
+)
+
+var operators = map[string]func(int) bool{
+	"eq": func(c int) bool { return c == 0 },
+	"ne": func(c int) bool { return c != 0 },
+	"lt": func(c int) bool { return c < 0 },
+	"le": func(c int) bool { return c <= 0 },
+	"gt": func(c int) bool { return c > 0 },
+	"ge": func(c int) bool { return c >= 0 },
+}
+
+// IsEmpty is true when neither a filter nor a sort order was requested.
+func (q Query) IsEmpty() bool {
+	return len(q.Filter) == 0 && len(q.Sort) == 0
+}
+
+// parseQuery reads ?filter= and ?sort= for a request to n, checking that
+// every property named is one of n's collection members' properties.
+func (n *Node) parseQuery(values url.Values) (q Query, err error) {
+	filter, sorting := values.Get(FILTER), values.Get(SORT)
+	if filter == "" && sorting == "" {
+		return q, nil
+	} else if n.ID_Child == nil {
+		return q, HttpError(400, n.EntityType.Name(), " is not a collection, so cannot be filtered or sorted.")
+	} else if q.Filter, err = parseFilter(filter); err != nil {
+		return q, err
+	}
+	for _, s := range strings.Split(sorting, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			q.Sort = append(q.Sort, SortKey{strings.TrimPrefix(s, "-"), strings.HasPrefix(s, "-")})
+		}
+	}
+	return q, q.verify(n.ID_Child.Node.EntityType)
+}
+
+func (q Query) verify(t reflect.Type) error {
+	for _, c := range q.Filter {
+		if !hasJSONProperty(t, c.Property) {
+			return HttpError(400, "Cannot filter on '", c.Property, "', ", t.Name(), " has no such property.")
+		}
+	}
+	for _, s := range q.Sort {
+		if !hasJSONProperty(t, s.Property) {
+			return HttpError(400, "Cannot sort on '", s.Property, "', ", t.Name(), " has no such property.")
+		}
+	}
+	return nil
+}
+
+func parseFilter(filter string) ([]Condition, error) {
+	conditions := []Condition{}
+	if strings.TrimSpace(filter) == "" {
+		return conditions, nil
+	}
+	tokens, err := tokeniseFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	for len(tokens) != 0 {
+		if len(tokens) < 3 {
+			return nil, HttpError(400, "Malformed filter '", filter, "', expected property op value.")
+		}
+		c := Condition{Property: tokens[0].text, Op: tokens[1].text}
+		if _, ok := operators[c.Op]; !ok || tokens[0].quoted || tokens[1].quoted {
+			return nil, HttpError(400, "Malformed filter '", filter, "', unknown operator '", c.Op, "'.")
+		} else if c.Value, err = tokens[2].value(); err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+		tokens = tokens[3:]
+		if len(tokens) != 0 {
+			if tokens[0].text != "and" || tokens[0].quoted {
+				return nil, HttpError(400, "Malformed filter '", filter, "', expected 'and' but got '", tokens[0].text, "'.")
+			}
+			tokens = tokens[1:]
+			if len(tokens) == 0 {
+				return nil, HttpError(400, "Malformed filter '", filter, "', it ends with 'and'.")
+			}
+		}
+	}
+	return conditions, nil
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+func (t token) value() (interface{}, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	switch t.text {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	case "null":
+		return nil, nil
+	}
+	if f, err := strconv.ParseFloat(t.text, 64); err == nil {
+		return f, nil
+	}
+	return nil, HttpError(400, "Filter value '", t.text, "' must be a quoted string, number, true, false or null.")
+}
+
+func tokeniseFilter(filter string) ([]token, error) {
+	tokens := []token{}
+	for i := 0; i < len(filter); {
+		switch {
+		case filter[i] == ' ':
+			i++
+		case filter[i] == '\'':
+			var text strings.Builder
+			for i++; ; i++ {
+				if i == len(filter) {
+					return nil, HttpError(400, "Malformed filter '", filter, "', unterminated string.")
+				} else if filter[i] != '\'' {
+					text.WriteByte(filter[i])
+				} else if i+1 < len(filter) && filter[i+1] == '\'' {
+					text.WriteByte('\'')
+					i++
+				} else {
+					break
+				}
+			}
+			tokens = append(tokens, token{text.String(), true})
+			i++
+		default:
+			end := strings.IndexByte(filter[i:], ' ')
+			if end == -1 {
+				end = len(filter) - i
+			}
+			tokens = append(tokens, token{filter[i : i+end], false})
+			i += end
+		}
+	}
+	return tokens, nil
+}
+
+// apply filters and sorts members in memory.
+func (q Query) apply(members []member) ([]member, error) {
+	props := map[string]map[string]interface{}{}
+	matching := []member{}
+	for _, m := range members {
+		if p, err := toMap(m.entity); err != nil {
+			return nil, err
+		} else if q.matches(*p) {
+			props[m.id] = *p
+			matching = append(matching, m)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		for _, s := range q.Sort {
+			c := compareValues(props[matching[i].id][s.Property], props[matching[j].id][s.Property])
+			if c != 0 {
+				return (c < 0) != s.Descending
+			}
+		}
+		return false
+	})
+	return matching, nil
+}
+
+func (q Query) matches(props map[string]interface{}) bool {
+	for _, c := range q.Filter {
+		a, b := props[c.Property], c.Value
+		if !orderable(a, b) && c.Op != "eq" && c.Op != "ne" {
+			return false
+		} else if !operators[c.Op](compareValues(a, b)) {
+			return false
+		}
+	}
+	return true
+}
+
+func orderable(a, b interface{}) bool {
+	return a != nil && b != nil && reflect.TypeOf(a) == reflect.TypeOf(b) && reflect.TypeOf(a).Kind() != reflect.Bool
+}
+
+// compareValues orders JSON values. Values of different types are ordered
+// null, bool, number, string, and anything else is considered equal.
+func compareValues(a, b interface{}) int {
+	if ra, rb := rank(a), rank(b); ra != rb {
+		return ra - rb
+	}
+	switch a := a.(type) {
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if !a {
+			return -1
+		}
+		return 1
+	case float64:
+		if a < b.(float64) {
+			return -1
+		} else if a > b.(float64) {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+func rank(v interface{}) int {
+	switch v.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// Query_C is the compiled form of a collection's Query method. The Cursors
+// are only set for collections which implement Page.
+// Params: request, parent, query
+type Query_C func(*Request, interface{}, Query) (reflect.Value, Cursors, error)
+
+var query_T = reflect.TypeOf(Query{})
+
+// compileQuery returns a Query_C for the collection type t, or nil if t does
+// not implement Query. The Query of a paged collection must return Cursors.
+func (n *Node) compileQuery(t reflect.Type, paged bool) (Query_C, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	m, ok := reflect.PtrTo(t).MethodByName("Query")
+	if !ok {
+		return nil, nil
+	}
+	mt := m.Type
+	args, ok := injectedArgs(mt, n.EntityPtrType, query_T)
+	if !ok {
+		return nil, Error("*", t.Name(), ".Query should have parameters (", n.EntityPtrType, ", halgo.Query), and may also take a context.Context and *halgo.Request")
+	} else if paged && (mt.NumOut() != 2 || mt.Out(0) != cursors_T || mt.Out(1) != error_T) {
+		return nil, Error("*", t.Name(), ".Query should have outputs (halgo.Cursors, error), as ", t.Name(), " implements Page")
+	} else if !paged && (mt.NumOut() != 1 || mt.Out(0) != error_T) {
+		return nil, Error("*", t.Name(), ".Query should have a single error output")
+	}
+	return func(r *Request, parent interface{}, q Query) (reflect.Value, Cursors, error) {
+		collection := reflect.New(t)
+		out := collection.MethodByName("Query").Call(args(r, parent, q))
+		if err := out[len(out)-1]; !err.IsNil() {
+			return collection, Cursors{}, err.Interface().(error)
+		} else if paged {
+			return collection, out[0].Interface().(Cursors), nil
+		}
+		return collection, Cursors{}, nil
+	}, nil
+}
@@ -0,0 +1,131 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_parseFilter(t *testing.T) {
+	conditions, err := parseFilter("name eq 'it''s' and count ge 2 and live ne true")
+	error_should_be_nil(t, err)
+	expected := []Condition{{"name", "eq", "it's"}, {"count", "ge", 2.0}, {"live", "ne", true}}
+	if !reflect.DeepEqual(conditions, expected) {
+		t.Errorf("Expected %v, got %v", expected, conditions)
+	}
+	conditions, err = parseFilter("name eq 'tést'")
+	error_should_be_nil(t, err)
+	if expected := []Condition{{"name", "eq", "tést"}}; !reflect.DeepEqual(conditions, expected) {
+		t.Errorf("Expected %v, got %v", expected, conditions)
+	}
+	_, err = parseFilter("name like 'x'")
+	error_should_contain(t, err, "unknown operator 'like'")
+	_, err = parseFilter("name eq 'x' or name eq 'y'")
+	error_should_contain(t, err, "expected 'and' but got 'or'")
+	_, err = parseFilter("name eq 'x")
+	error_should_contain(t, err, "unterminated string")
+	_, err = parseFilter("name eq x")
+	error_should_contain(t, err, "must be a quoted string")
+}
+
+func Test_Query_apply(t *testing.T) {
+	members := []member{
+		{"0.2.0", &AppVersion{"a", "test-app", "0.2.0"}},
+		{"1.2.3", &AppVersion{"b", "other-app", "1.2.3"}},
+		{"1.3.9", &AppVersion{"c", "test-app", "1.3.9"}},
+	}
+	q := Query{
+		Filter: []Condition{{"name", "eq", "test-app"}},
+		Sort:   []SortKey{{"version", true}},
+	}
+	if matching, err := q.apply(members); err != nil {
+		t.Error(err)
+	} else if len(matching) != 2 || matching[0].id != "1.3.9" || matching[1].id != "0.2.0" {
+		t.Errorf("Expected 1.3.9 then 0.2.0, got %v", matching)
+	}
+}
+
+// Query supports filtering on name equality and sorting by name or price. Its
+// cursors are product names, as are those of Page.
+func (l *ProductList) Query(parent *Shop, q Query) (Cursors, error) {
+	products := []Product{}
+	for _, p := range parent.Products {
+		matches := true
+		for _, c := range q.Filter {
+			matches = matches && c.Property == "name" && (c.Op == "eq") == (c.Value == p.Name)
+		}
+		if matches {
+			products = append(products, p)
+		}
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Name < products[j].Name })
+	for _, s := range q.Sort {
+		if s.Property == "price" {
+			sort.SliceStable(products, func(i, j int) bool { return products[i].Price < products[j].Price })
+		}
+		if s.Descending {
+			for i, j := 0, len(products)-1; i < j; i, j = i+1, j-1 {
+				products[i], products[j] = products[j], products[i]
+			}
+		}
+	}
+	names := []string{}
+	for _, p := range products {
+		names = append(names, p.Name)
+	}
+	return l.page(parent, names, q.Cursor, q.Limit), nil
+}
+
+func Test_ServeHTTP_FilterAndSortBeforePaging(t *testing.T) {
+	g, err := Graph(Shop{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		target string
+		names  []string
+		next   string
+	}{
+		{"/?limit=1&filter=name+eq+'cherry'", []string{"cherry"}, ""},
+		{"/?limit=1&filter=name+ne+'apple'", []string{"banana"}, "/?cursor=banana&filter=name+ne+%27apple%27&limit=1"},
+		{"/?limit=2&sort=-price", []string{"cherry", "apple"}, "/?cursor=apple&limit=2&sort=-price"},
+		{"/?cursor=apple&limit=2&sort=-price", []string{"damson", "banana"}, ""},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", c.target, nil))
+		if w.Code != 200 {
+			t.Errorf("GET %v: expected 200 but got %v %s", c.target, w.Code, w.Body)
+			continue
+		}
+		doc := halBody(t, w)
+		if names := productNames(doc); !reflect.DeepEqual(names, c.names) {
+			t.Errorf("GET %v: expected products %v but got %v", c.target, c.names, names)
+		}
+		if next, _ := lookup(doc, "_links", "next", "href").(string); next != c.next {
+			t.Errorf("GET %v: expected next link %q but got %q", c.target, c.next, next)
+		}
+	}
+}
+
+func Test_ServeHTTP_FollowFilteredPages(t *testing.T) {
+	g, err := Graph(Shop{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for target := "/?limit=1&filter=name+ne+'cherry'"; target != ""; {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		doc := halBody(t, w)
+		names = append(names, productNames(doc)...)
+		if len(names) > len(the_products) {
+			t.Fatalf("Expected paging to end, but got %v", names)
+		}
+		target, _ = lookup(doc, "_links", "next", "href").(string)
+	}
+	if expected := []string{"apple", "banana", "damson"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("Expected following next from a filtered page to give %v but got %v", expected, names)
+	}
+}
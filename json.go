@@ -1,22 +1,22 @@
 package halgo
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"reflect"
 )
 
-// TODO: Return 400 Bad Request if deserialisation fails completely
-//       Invoke validation and return 422 (Maybe) if validation fails.
-
 func prepare_payload(body io.ReadCloser, t reflect.Type) (interface{}, error) {
 	v := reflect.New(t).Interface()
 	defer body.Close()
 	if buf, err := ioutil.ReadAll(body); err != nil {
 		return nil, err
 	} else if err := json.Unmarshal(buf, &v); err != nil {
-		return nil, Error("Unable to deserialise: ", string(buf), " into type ", t.Name(), " ... ", err.Error())
+		return nil, HttpError(400, "Unable to deserialise: ", string(buf), " into type ", t.Name(), " ... ", err.Error())
 	} else {
 		return v, nil
 	}
@@ -33,3 +33,64 @@ func json_error(err error) []byte {
 		return []byte("Unable to serialise error: '" + err.Error() + "'")
 	}
 }
+
+// marshalIndent is json.MarshalIndent without HTML escaping, so that hrefs
+// containing query strings stay readable.
+func marshalIndent(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	e.SetIndent("", "\t")
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// marshal is json.Marshal without HTML escaping.
+func marshal(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	e := json.NewEncoder(buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// decodeBody decodes the JSON request body into a new value of type t, and
+// checks it against any validate tags.
+func decodeBody(body io.ReadCloser, t reflect.Type) (interface{}, error) {
+	defer body.Close()
+	target := t
+	if t.Kind() == reflect.Ptr {
+		target = t.Elem()
+	}
+	v := reflect.New(target)
+	if buf, err := ioutil.ReadAll(body); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(buf, v.Interface()); err != nil {
+		return nil, HttpError(400, "Malformed ", target.Name(), ": ", decodeError(err))
+	} else if err := checkRules(v.Interface()); err != nil {
+		return nil, err
+	} else if t.Kind() == reflect.Ptr {
+		return v.Interface(), nil
+	} else {
+		return v.Elem().Interface(), nil
+	}
+}
+
+// decodeError describes why a request body could not be decoded, without
+// repeating the body or naming Go types.
+func decodeError(err error) string {
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &syntaxErr) {
+		return fmt.Sprint("invalid JSON at offset ", syntaxErr.Offset, ".")
+	} else if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return fmt.Sprint("'", typeErr.Field, "' cannot be a JSON ", typeErr.Value, ".")
+	} else if errors.As(err, &typeErr) {
+		return fmt.Sprint("the body cannot be a JSON ", typeErr.Value, ".")
+	}
+	return "the body is not valid JSON."
+}
@@ -0,0 +1,105 @@
+package halgo
+
+// JSONAPIRenderer renders application/vnd.api+json. Each resource's type is
+// the name of its Go type and its id is its self href. Self, up and paging
+// links stay links, while links to children become relationships. Embedded
+// resources are also listed in the top-level included array.
+type JSONAPIRenderer struct{}
+
+func (JSONAPIRenderer) MediaType() string {
+	return "application/vnd.api+json"
+}
+
+func (JSONAPIRenderer) Render(r *Resource) ([]byte, error) {
+	doc := jsonAPIDocument{Data: jsonAPI(r)}
+	seen := map[string]bool{r.selfHref(): true}
+	doc.Included = jsonAPIIncluded(r, seen, []jsonAPIResource{})
+	return marshalIndent(doc)
+}
+
+type jsonAPIDocument struct {
+	Data     jsonAPIResource   `json:"data"`
+	Included []jsonAPIResource `json:"included,omitempty"`
+}
+
+type jsonAPIResource struct {
+	Type          string                         `json:"type"`
+	ID            string                         `json:"id"`
+	Attributes    map[string]interface{}         `json:"attributes,omitempty"`
+	Relationships map[string]jsonAPIRelationship `json:"relationships,omitempty"`
+	Links         map[string]string              `json:"links,omitempty"`
+}
+
+type jsonAPIRelationship struct {
+	Links map[string]string `json:"links,omitempty"`
+	Data  interface{}       `json:"data,omitempty"`
+}
+
+type jsonAPIIdentifier struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+func (r *Resource) jsonAPIIdentifier() jsonAPIIdentifier {
+	return jsonAPIIdentifier{r.typeName(), r.selfHref()}
+}
+
+// jsonAPILinks are the rels which are links of the resource itself, rather
+// than relationships to other resources.
+var jsonAPILinks = map[string]bool{"self": true, "up": true, "first": true, "next": true, "prev": true}
+
+func jsonAPI(r *Resource) jsonAPIResource {
+	res := jsonAPIResource{
+		Type:          r.typeName(),
+		ID:            r.selfHref(),
+		Attributes:    r.Entity,
+		Relationships: map[string]jsonAPIRelationship{},
+		Links:         map[string]string{},
+	}
+	for rel, l := range r.Links {
+		if l, ok := l.(Link); !ok {
+			continue
+		} else if jsonAPILinks[rel] {
+			res.Links[rel] = l.Href
+		} else if !l.Templated {
+			res.Relationships[rel] = jsonAPIRelationship{Links: map[string]string{"related": l.Href}}
+		}
+	}
+	for rel, e := range r.Embedded {
+		relationship := res.Relationships[rel]
+		switch e := e.(type) {
+		case *Resource:
+			relationship.Data = e.jsonAPIIdentifier()
+		case []*Resource:
+			ids := []jsonAPIIdentifier{}
+			for _, item := range e {
+				ids = append(ids, item.jsonAPIIdentifier())
+			}
+			relationship.Data = ids
+		}
+		res.Relationships[rel] = relationship
+	}
+	return res
+}
+
+// jsonAPIIncluded appends every resource embedded beneath r which has
+// attributes of its own, depth first, skipping any already seen.
+func jsonAPIIncluded(r *Resource, seen map[string]bool, included []jsonAPIResource) []jsonAPIResource {
+	for _, rel := range sortedKeys(r.Embedded) {
+		items := []*Resource{}
+		switch e := r.Embedded[rel].(type) {
+		case *Resource:
+			items = append(items, e)
+		case []*Resource:
+			items = append(items, e...)
+		}
+		for _, item := range items {
+			if id := item.selfHref(); item.Entity != nil && !seen[id] {
+				seen[id] = true
+				included = append(included, jsonAPI(item))
+			}
+			included = jsonAPIIncluded(item, seen, included)
+		}
+	}
+	return included
+}
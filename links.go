@@ -0,0 +1,59 @@
+package halgo
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Link is a HAL link object. Templated links have an RFC 6570 URI template
+// as their Href, which can be filled in using Expand.
+type Link struct {
+	Href      string `json:"href"`
+	Templated bool   `json:"templated,omitempty"`
+}
+
+// Expand returns l with its template expanded using vars. Links which are
+// not templated are returned as is.
+func (l Link) Expand(vars map[string]string) Link {
+	if !l.Templated {
+		return l
+	}
+	return Link{Href: Expand(l.Href, vars)}
+}
+
+// Expand expands an RFC 6570 level 1 URI template such as "/apps/{app}".
+// Values are percent-encoded, and variables missing from vars expand to the
+// empty string, as the RFC requires.
+func Expand(template string, vars map[string]string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(template, "{")
+		if start == -1 {
+			break
+		}
+		end := strings.Index(template[start:], "}")
+		if end == -1 {
+			break
+		}
+		b.WriteString(template[:start])
+		b.WriteString(escapeUnreserved(vars[template[start+1:start+end]]))
+		template = template[start+end+1:]
+	}
+	b.WriteString(template)
+	return b.String()
+}
+
+// escapeUnreserved percent-encodes everything in s except the RFC 3986
+// unreserved characters.
+func escapeUnreserved(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("-._~", c) != -1 {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
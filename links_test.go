@@ -0,0 +1,84 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_Expand(t *testing.T) {
+	vars := map[string]string{"app": "test-app", "appversion": "1.2.3", "odd": "a b/c"}
+	cases := map[string]string{
+		"/apps/{app}":              "/apps/test-app",
+		"/apps/{app}/{appversion}": "/apps/test-app/1.2.3",
+		"/apps/{odd}":              "/apps/a%20b%2Fc",
+		"/apps/{missing}":          "/apps/",
+		"/apps":                    "/apps",
+		"/apps/{unterminated":      "/apps/{unterminated",
+	}
+	for template, expected := range cases {
+		if actual := Expand(template, vars); actual != expected {
+			t.Errorf("Expand(%q) = %q, expected %q", template, actual, expected)
+		}
+	}
+}
+
+func Test_Link_Expand(t *testing.T) {
+	l := Link{Href: "/apps/{app}", Templated: true}.Expand(map[string]string{"app": "test-app"})
+	if l.Templated || l.Href != "/apps/test-app" {
+		t.Errorf("Expected untemplated link to /apps/test-app, got %+v", l)
+	}
+}
+
+func Test_ServeHTTP_Links(t *testing.T) {
+	root := halBody(t, serve(t, "GET", "/", ""))
+	if up := lookup(root, "_links", "up"); up != nil {
+		t.Errorf("Expected / to have no up link but got %v", up)
+	}
+	if health := lookup(root, "_links", "health", "href"); health != "/health" {
+		t.Errorf("Expected a link to /health but got %v", health)
+	}
+	if apps := lookup(root, "_links", "apps", "href"); apps != "/apps" {
+		t.Errorf("Expected a link to /apps but got %v", apps)
+	}
+	app := halBody(t, serve(t, "GET", "/apps/test-app", ""))
+	if up := lookup(app, "_links", "up", "href"); up != "/apps" {
+		t.Errorf("Expected /apps/test-app to have an up link to /apps but got %v", up)
+	}
+	if self := lookup(app, "_links", "self", "href"); self != "/apps/test-app" {
+		t.Errorf("Expected a self link to /apps/test-app but got %v", self)
+	}
+}
+
+type Surgery struct {
+	Name   string  `json:"name"`
+	Doctor *Doctor `json:"doctor" halgo:"link(rel=physician) embed(href)"`
+}
+
+func (s *Surgery) Manifest() error {
+	s.Name = "Surgery"
+	return nil
+}
+
+type Doctor struct {
+	Name string `json:"name"`
+}
+
+func (d *Doctor) Manifest() error {
+	d.Name = "Dr. Who"
+	return nil
+}
+
+func Test_ServeHTTP_LinkRel(t *testing.T) {
+	g, err := Graph(Surgery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	doc := halBody(t, w)
+	if physician := lookup(doc, "_links", "physician", "href"); physician != "/doctor" {
+		t.Errorf("Expected link(rel=physician) to link to /doctor but got %v", doc["_links"])
+	} else if doctor := lookup(doc, "_links", "doctor"); doctor != nil {
+		t.Errorf("Expected no link with the field's rel but got %v", doctor)
+	}
+}
@@ -0,0 +1,58 @@
+package halgo
+
+import (
+	"net/http"
+	"path"
+)
+
+// NodeDescription is the body of an OPTIONS response, describing what can be
+// done with a node.
+type NodeDescription struct {
+	// Type is the Go type of the node's entity.
+	Type string `json:"type"`
+	// Allow lists the supported methods, as in the Allow header.
+	Allow []string `json:"allow"`
+	// MediaTypes lists the representations GET can produce.
+	MediaTypes []string `json:"mediaTypes,omitempty"`
+	// Accept lists the media types accepted by PUT and POST.
+	Accept []string `json:"accept,omitempty"`
+	// AcceptPatch lists the patch formats accepted by PATCH.
+	AcceptPatch []string `json:"acceptPatch,omitempty"`
+	// Links maps the rels of the node's children to their hrefs.
+	Links map[string]Link `json:"links,omitempty"`
+}
+
+func (root *Node) describe(n *Node, href string) NodeDescription {
+	d := NodeDescription{Type: n.EntityType.Name(), Allow: *n.AllowedMethods()}
+	if n.SupportsGET() {
+		for _, r := range root.Renderers {
+			d.MediaTypes = append(d.MediaTypes, r.MediaType())
+		}
+	}
+	if n.SupportsPUT() || n.SupportsPOST() {
+		d.Accept = []string{"application/json"}
+	}
+	if n.SupportsPATCH() {
+		d.AcceptPatch = []string{MergePatchType, JSONPatchType}
+	}
+	if n.ID_Child != nil {
+		c := n.ID_Child
+		d.Links = map[string]Link{c.Rel(): {Href: path.Join(href, "{"+c.Var()+"}"), Templated: true}}
+	} else if n.Children != nil && len(*n.Children) != 0 {
+		d.Links = map[string]Link{}
+		for name, c := range *n.Children {
+			d.Links[c.Rel()] = Link{Href: path.Join(href, name)}
+		}
+	}
+	return d
+}
+
+// headResponseWriter discards the body of responses to HEAD requests, so that
+// they run exactly the same code as GET and so get the same headers.
+type headResponseWriter struct {
+	http.ResponseWriter
+}
+
+func (w headResponseWriter) Write(b []byte) (int, error) {
+	return len(b), nil
+}
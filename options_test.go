@@ -0,0 +1,54 @@
+package halgo
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_ServeHTTP_OPTIONS(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("OPTIONS", "/apps/test-app", nil))
+	if allow := w.Header().Get("Allow"); w.Code != 200 || allow != "HEAD, GET, OPTIONS" {
+		t.Errorf("Expected 200 with Allow: HEAD, GET, OPTIONS but got %v %q", w.Code, allow)
+	}
+	var d NodeDescription
+	error_should_be_nil(t, json.Unmarshal(w.Body.Bytes(), &d))
+	expected := map[string]Link{"versions": {Href: "/apps/test-app/{appversion}", Templated: true}}
+	if d.Type != "App" || !reflect.DeepEqual(d.Links, expected) {
+		t.Errorf("Expected an App with links %v but got %+v", expected, d)
+	}
+}
+
+func Test_ServeHTTP_HEAD(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	get, head := httptest.NewRecorder(), httptest.NewRecorder()
+	g.ServeHTTP(get, httptest.NewRequest("GET", "/apps/test-app", nil))
+	g.ServeHTTP(head, httptest.NewRequest("HEAD", "/apps/test-app", nil))
+	if head.Code != 200 || head.Body.Len() != 0 {
+		t.Errorf("Expected an empty 200 but got %v %q", head.Code, head.Body)
+	}
+	if !reflect.DeepEqual(get.Header(), head.Header()) {
+		t.Errorf("Expected HEAD headers\n\t%v\nto match GET headers\n\t%v", head.Header(), get.Header())
+	}
+}
+
+func Test_ServeHTTP_405HasAllow(t *testing.T) {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("DELETE", "/apps", nil))
+	if allow := w.Header().Get("Allow"); w.Code != 405 || allow != "HEAD, GET, OPTIONS" {
+		t.Errorf("Expected 405 with Allow: HEAD, GET, OPTIONS but got %v %q", w.Code, allow)
+	}
+}
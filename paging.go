@@ -0,0 +1,116 @@
+package halgo
+
+import (
+	"net/url"
+	"reflect"
+	"strconv"
+)
+
+// DefaultPageLimit is the number of members in a page of a collection when
+// the client does not specify ?limit=. MaxPageLimit is the most a client may
+// ask for; larger limits are reduced to it.
+const (
+	DefaultPageLimit = 20
+	MaxPageLimit     = 100
+)
+
+// Cursors are returned by a collection's Page method to say where the
+// neighbouring pages start. Next should be "" on the last page. Prev is only
+// used when the current page is not the first, and may be "" to mean the
+// first page.
+//
+// A pageable collection type implements:
+//
+//	func (l *AppsList) Page(parent *Apps, cursor string, limit int) (halgo.Cursors, error)
+//
+// populating l with at most limit members starting at cursor, where cursor
+// is "" for the first page.
+type Cursors struct {
+	Next string
+	Prev string
+}
+
+// Page_C is the compiled form of a collection's Page method.
+// Params: request, parent, cursor, limit
+type Page_C func(*Request, interface{}, string, int) (reflect.Value, Cursors, error)
+
+var (
+	cursors_T = reflect.TypeOf(Cursors{})
+	int_T     = reflect.TypeOf(0)
+)
+
+type page struct {
+	cursor string
+	limit  int
+}
+
+var firstPage = page{"", DefaultPageLimit}
+
+func parsePage(q url.Values) (page, error) {
+	p := page{q.Get(CURSOR), DefaultPageLimit}
+	if l := q.Get(LIMIT); l != "" {
+		if limit, err := strconv.Atoi(l); err != nil || limit < 1 {
+			return p, HttpError(400, "Parameter 'limit' must be a positive integer, not '", l, "'.")
+		} else if limit > MaxPageLimit {
+			p.limit = MaxPageLimit
+		} else {
+			p.limit = limit
+		}
+	}
+	return p, nil
+}
+
+// compilePage returns a Page_C for the collection type t, or nil if t does not
+// implement Page.
+func (n *Node) compilePage(t reflect.Type) (Page_C, error) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	m, ok := reflect.PtrTo(t).MethodByName("Page")
+	if !ok {
+		return nil, nil
+	}
+	mt := m.Type
+	args, ok := injectedArgs(mt, n.EntityPtrType, string_T, int_T)
+	if !ok {
+		return nil, Error("*", t.Name(), ".Page should have parameters (", n.EntityPtrType, ", string, int), and may also take a context.Context and *halgo.Request")
+	} else if mt.NumOut() != 2 || mt.Out(0) != cursors_T || mt.Out(1) != error_T {
+		return nil, Error("*", t.Name(), ".Page should have outputs (halgo.Cursors, error)")
+	}
+	return func(r *Request, parent interface{}, cursor string, limit int) (reflect.Value, Cursors, error) {
+		collection := reflect.New(t)
+		out := collection.MethodByName("Page").Call(args(r, parent, cursor, limit))
+		if err := out[1]; !err.IsNil() {
+			return collection, Cursors{}, err.Interface().(error)
+		}
+		return collection, out[0].Interface().(Cursors), nil
+	}, nil
+}
+
+// addPageLinks adds first, next and prev links to r, which has the href
+// base and a paged collection child.
+func (v view) addPageLinks(r *Resource, base string, c Cursors) {
+	if base == "" {
+		base = "/"
+	}
+	r.Links["first"] = Link{Href: v.pageHref(base, "")}
+	if c.Next != "" {
+		r.Links["next"] = Link{Href: v.pageHref(base, c.Next)}
+	}
+	if v.page.cursor != "" {
+		r.Links["prev"] = Link{Href: v.pageHref(base, c.Prev)}
+	}
+}
+
+func (v view) pageHref(base string, cursor string) string {
+	q := url.Values{}
+	for k, values := range v.query {
+		q[k] = values
+	}
+	q.Del(CURSOR)
+	if cursor != "" {
+		q.Set(CURSOR, cursor)
+	}
+	q.Set(LIMIT, strconv.Itoa(v.page.limit))
+	return base + "?" + q.Encode()
+}
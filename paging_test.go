@@ -0,0 +1,163 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func Test_parsePage(t *testing.T) {
+	p, err := parsePage(url.Values{"cursor": {"b"}, "limit": {"2"}})
+	error_should_be_nil(t, err)
+	if p.cursor != "b" || p.limit != 2 {
+		t.Errorf("Expected cursor b, limit 2, got %+v", p)
+	}
+	if p, _ := parsePage(url.Values{}); p != firstPage {
+		t.Errorf("Expected %+v, got %+v", firstPage, p)
+	}
+	if p, _ := parsePage(url.Values{"limit": {"1000000"}}); p.limit != MaxPageLimit {
+		t.Errorf("Expected limit to be capped at %v, got %v", MaxPageLimit, p.limit)
+	}
+	_, err = parsePage(url.Values{"limit": {"0"}})
+	error_should_contain(t, err, "must be a positive integer")
+}
+
+func Test_addPageLinks(t *testing.T) {
+	v := view{page: page{"b", 2}, query: url.Values{"embed": {"apps"}, "cursor": {"b"}}}
+	r := &Resource{Links: map[string]interface{}{}}
+	v.addPageLinks(r, "/apps", Cursors{Next: "d"})
+	expected := map[string]string{
+		"first": "/apps?embed=apps&limit=2",
+		"next":  "/apps?cursor=d&embed=apps&limit=2",
+		"prev":  "/apps?embed=apps&limit=2",
+	}
+	for rel, href := range expected {
+		if l, ok := r.Links[rel].(Link); !ok || l.Href != href {
+			t.Errorf("Expected %v link to %v, got %v", rel, href, r.Links[rel])
+		}
+	}
+}
+
+type Shop struct {
+	Name     string      `json:"name"`
+	Products ProductList `json:"products" halgo:"embed()"`
+}
+
+func (s *Shop) Manifest() error {
+	*s = Shop{Name: "shop", Products: the_products}
+	return nil
+}
+
+type Product struct {
+	Name  string `json:"name"`
+	Price int    `json:"price"`
+}
+
+func (p *Product) Manifest(parent *Shop, id string) error {
+	if product, ok := parent.Products[id]; !ok {
+		return Error404(id)
+	} else {
+		*p = product
+	}
+	return nil
+}
+
+type ProductList map[string]Product
+
+var the_products = ProductList{
+	"apple":  {"apple", 3},
+	"banana": {"banana", 1},
+	"cherry": {"cherry", 5},
+	"damson": {"damson", 2},
+}
+
+// Page starts after the product named cursor, in name order.
+func (l *ProductList) Page(parent *Shop, cursor string, limit int) (Cursors, error) {
+	names := []string{}
+	for name := range parent.Products {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return l.page(parent, names, cursor, limit), nil
+}
+
+// page populates l with the products named in names after cursor, returning
+// the names of the last products on the next and previous pages.
+func (l *ProductList) page(parent *Shop, names []string, cursor string, limit int) Cursors {
+	start := 0
+	for i, name := range names {
+		if name == cursor {
+			start = i + 1
+		}
+	}
+	end := start + limit
+	if end > len(names) {
+		end = len(names)
+	}
+	(*l) = ProductList{}
+	for _, name := range names[start:end] {
+		(*l)[name] = parent.Products[name]
+	}
+	c := Cursors{}
+	if end < len(names) {
+		c.Next = names[end-1]
+	}
+	if start-limit > 0 {
+		c.Prev = names[start-limit-1]
+	}
+	return c
+}
+
+func Test_ServeHTTP_Paging(t *testing.T) {
+	g, err := Graph(Shop{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := [][]string{{"apple", "banana"}, {"cherry", "damson"}}
+	target := "/?limit=2"
+	for i, expected := range pages {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		doc := halBody(t, w)
+		if names := productNames(doc); !reflect.DeepEqual(names, expected) {
+			t.Errorf("GET %v: expected %v but got %v", target, expected, names)
+		}
+		next, _ := lookup(doc, "_links", "next", "href").(string)
+		if i == len(pages)-1 {
+			if next != "" || lookup(doc, "_links", "prev", "href") != "/?limit=2" {
+				t.Errorf("GET %v: expected a prev link but no next link, got %v", target, doc["_links"])
+			}
+		} else if next == "" {
+			t.Fatalf("GET %v: expected a next link, got %v", target, doc["_links"])
+		}
+		target = next
+	}
+}
+
+// productNames lists the names of the products embedded in doc, in order.
+func productNames(doc map[string]interface{}) []string {
+	names := []string{}
+	products, _ := lookup(doc, "_embedded", "products").([]interface{})
+	for _, p := range products {
+		name, _ := lookup(p, "name").(string)
+		names = append(names, name)
+	}
+	return names
+}
+
+func Test_ServeHTTP_PagingParamsOnlyOnPagedCollections(t *testing.T) {
+	if w := serve(t, "GET", "/apps/test-app/1.2.3?limit=abc&cursor=x", ""); w.Code != 200 {
+		t.Errorf("Expected ?limit= to be ignored on an entity but got %v %s", w.Code, w.Body)
+	}
+	g, err := Graph(Shop{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/?limit=abc", nil))
+	if w.Code != 400 {
+		t.Errorf("Expected 400 for ?limit=abc on a paged collection but got %v", w.Code)
+	}
+}
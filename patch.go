@@ -0,0 +1,277 @@
+package halgo
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Media types accepted by PATCH.
+const (
+	MergePatchType = "application/merge-patch+json"
+	JSONPatchType  = "application/json-patch+json"
+)
+
+var acceptPatch = MergePatchType + ", " + JSONPatchType
+
+// patchEntity applies the patch document in body, of the given content type,
+// to entity, returning a new entity of the same type.
+func patchEntity(entity interface{}, contentType string, body io.ReadCloser) (interface{}, error) {
+	defer body.Close()
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType != MergePatchType && mediaType != JSONPatchType {
+		return nil, HttpError(415, "PATCH requires a Content-Type of ", acceptPatch, ", not '", contentType, "'.")
+	}
+	var doc interface{}
+	if buf, err := ioutil.ReadAll(body); err != nil {
+		return nil, err
+	} else if current, err := json.Marshal(entity); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(current, &doc); err != nil {
+		return nil, err
+	} else if mediaType == MergePatchType {
+		var patch interface{}
+		if err := json.Unmarshal(buf, &patch); err != nil {
+			return nil, HttpError(400, "Malformed merge patch: ", err.Error())
+		}
+		doc = mergePatch(doc, patch)
+	} else {
+		var ops []patchOp
+		if err := json.Unmarshal(buf, &ops); err != nil {
+			return nil, HttpError(400, "Malformed JSON patch: ", err.Error())
+		} else if doc, err = jsonPatch(doc, ops); err != nil {
+			return nil, err
+		}
+	}
+	patched := reflect.New(reflect.TypeOf(entity).Elem())
+	if buf, err := json.Marshal(doc); err != nil {
+		return nil, err
+	} else if err := json.Unmarshal(buf, patched.Interface()); err != nil {
+		return nil, HttpError(422, "The patched document is not a valid ", patched.Elem().Type().Name(), ": ", err.Error())
+	} else if err := checkRules(patched.Interface()); err != nil {
+		return nil, err
+	}
+	return patched.Interface(), nil
+}
+
+// mergePatch implements RFC 7396 JSON Merge Patch.
+func mergePatch(target, patch interface{}) interface{} {
+	p, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	t, ok := target.(map[string]interface{})
+	if !ok {
+		t = map[string]interface{}{}
+	}
+	for k, v := range p {
+		if v == nil {
+			delete(t, k)
+		} else {
+			t[k] = mergePatch(t[k], v)
+		}
+	}
+	return t
+}
+
+type patchOp struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	From  string      `json:"from"`
+	Value interface{} `json:"value"`
+}
+
+// jsonPatch implements RFC 6902 JSON Patch. Failed tests are 409 Conflict,
+// and operations on paths which do not exist are 422 Unprocessable Entity.
+func jsonPatch(doc interface{}, ops []patchOp) (interface{}, error) {
+	var err error
+	for _, op := range ops {
+		switch op.Op {
+		case "add":
+			doc, err = pointerAdd(doc, op.Path, op.Value)
+		case "remove":
+			doc, _, err = pointerRemove(doc, op.Path)
+		case "replace":
+			if doc, _, err = pointerRemove(doc, op.Path); err == nil {
+				doc, err = pointerAdd(doc, op.Path, op.Value)
+			}
+		case "move":
+			var v interface{}
+			if doc, v, err = pointerRemove(doc, op.From); err == nil {
+				doc, err = pointerAdd(doc, op.Path, v)
+			}
+		case "copy":
+			var v interface{}
+			if v, err = pointerGet(doc, op.From); err == nil {
+				doc, err = pointerAdd(doc, op.Path, deepCopy(v))
+			}
+		case "test":
+			var v interface{}
+			if v, err = pointerGet(doc, op.Path); err == nil && !reflect.DeepEqual(v, op.Value) {
+				err = HttpError(409, "JSON patch test failed at '", op.Path, "'.")
+			}
+		default:
+			err = HttpError(400, "Unknown JSON patch operation '", op.Op, "'.")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+func deepCopy(v interface{}) interface{} {
+	var c interface{}
+	buf, _ := json.Marshal(v)
+	json.Unmarshal(buf, &c)
+	return c
+}
+
+// splitPointer splits an RFC 6901 JSON pointer into its unescaped tokens.
+func splitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	} else if !strings.HasPrefix(pointer, "/") {
+		return nil, HttpError(400, "Malformed JSON pointer '", pointer, "'.")
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, t := range tokens {
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+func pointerError(pointer string) error {
+	return HttpError(422, "JSON pointer '", pointer, "' does not exist in the document.")
+}
+
+func arrayIndex(token string, length int, pointer string) (int, error) {
+	if i, err := strconv.Atoi(token); err != nil || i < 0 || i >= length {
+		return 0, pointerError(pointer)
+	} else {
+		return i, nil
+	}
+}
+
+func pointerGet(doc interface{}, pointer string) (interface{}, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range tokens {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			var ok bool
+			if doc, ok = d[t]; !ok {
+				return nil, pointerError(pointer)
+			}
+		case []interface{}:
+			if i, err := arrayIndex(t, len(d), pointer); err != nil {
+				return nil, err
+			} else {
+				doc = d[i]
+			}
+		default:
+			return nil, pointerError(pointer)
+		}
+	}
+	return doc, nil
+}
+
+// pointerParent returns the container the last token of pointer refers into,
+// and that last token.
+func pointerParent(doc interface{}, pointer string) (interface{}, string, error) {
+	tokens, err := splitPointer(pointer)
+	if err != nil {
+		return nil, "", err
+	} else if len(tokens) == 0 {
+		return nil, "", nil
+	}
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	parent, err := pointerGet(doc, parentPointer)
+	return parent, tokens[len(tokens)-1], err
+}
+
+func pointerAdd(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	parent, last, err := pointerParent(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+	case []interface{}:
+		i := len(p)
+		if last != "-" {
+			if i, err = arrayIndex(last, len(p)+1, pointer); err != nil {
+				return nil, err
+			}
+		}
+		p = append(p[:i], append([]interface{}{value}, p[i:]...)...)
+		return pointerSet(doc, pointer[:strings.LastIndex(pointer, "/")], p)
+	default:
+		return nil, pointerError(pointer)
+	}
+	return doc, nil
+}
+
+// pointerSet replaces the existing value at pointer.
+func pointerSet(doc interface{}, pointer string, value interface{}) (interface{}, error) {
+	if pointer == "" {
+		return value, nil
+	}
+	parent, last, err := pointerParent(doc, pointer)
+	if err != nil {
+		return nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = value
+	case []interface{}:
+		if i, err := arrayIndex(last, len(p), pointer); err != nil {
+			return nil, err
+		} else {
+			p[i] = value
+		}
+	default:
+		return nil, pointerError(pointer)
+	}
+	return doc, nil
+}
+
+func pointerRemove(doc interface{}, pointer string) (interface{}, interface{}, error) {
+	if pointer == "" {
+		return nil, doc, nil
+	}
+	parent, last, err := pointerParent(doc, pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		if v, ok := p[last]; !ok {
+			return nil, nil, pointerError(pointer)
+		} else {
+			delete(p, last)
+			return doc, v, nil
+		}
+	case []interface{}:
+		if i, err := arrayIndex(last, len(p), pointer); err != nil {
+			return nil, nil, err
+		} else {
+			v := p[i]
+			p = append(p[:i:i], p[i+1:]...)
+			doc, err = pointerSet(doc, pointer[:strings.LastIndex(pointer, "/")], p)
+			return doc, v, err
+		}
+	default:
+		return nil, nil, pointerError(pointer)
+	}
+}
@@ -0,0 +1,68 @@
+package halgo
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_mergePatch(t *testing.T) {
+	// The example from RFC 7396, section 3.
+	var target, patch, expected interface{}
+	json.Unmarshal([]byte(`{"title":"Goodbye!","author":{"givenName":"John","familyName":"Doe"},"tags":["example","sample"],"content":"This will be unchanged"}`), &target)
+	json.Unmarshal([]byte(`{"title":"Hello!","phoneNumber":"+01-123-456-7890","author":{"familyName":null},"tags":["example"]}`), &patch)
+	json.Unmarshal([]byte(`{"title":"Hello!","author":{"givenName":"John"},"tags":["example"],"content":"This will be unchanged","phoneNumber":"+01-123-456-7890"}`), &expected)
+	if actual := mergePatch(target, patch); !reflect.DeepEqual(actual, expected) {
+		t.Errorf("Expected %v, got %v", expected, actual)
+	}
+}
+
+func Test_jsonPatch(t *testing.T) {
+	cases := []struct{ doc, patch, expected string }{
+		{`{"foo":"bar"}`, `[{"op":"add","path":"/baz","value":"qux"}]`, `{"baz":"qux","foo":"bar"}`},
+		{`{"foo":["bar","baz"]}`, `[{"op":"add","path":"/foo/1","value":"qux"}]`, `{"foo":["bar","qux","baz"]}`},
+		{`{"foo":["bar"]}`, `[{"op":"add","path":"/foo/-","value":"qux"}]`, `{"foo":["bar","qux"]}`},
+		{`{"foo":["bar","qux","baz"]}`, `[{"op":"remove","path":"/foo/1"}]`, `{"foo":["bar","baz"]}`},
+		{`{"baz":"qux","foo":"bar"}`, `[{"op":"replace","path":"/baz","value":"boo"}]`, `{"baz":"boo","foo":"bar"}`},
+		{`{"foo":{"waldo":"fred"},"qux":{}}`, `[{"op":"move","from":"/foo/waldo","path":"/qux/thud"}]`, `{"foo":{},"qux":{"thud":"fred"}}`},
+		{`{"a/b":1}`, `[{"op":"copy","from":"/a~1b","path":"/c"},{"op":"test","path":"/c","value":1}]`, `{"a/b":1,"c":1}`},
+		{`[[1,2],[3]]`, `[{"op":"add","path":"/0/1","value":9}]`, `[[1,9,2],[3]]`},
+	}
+	for _, c := range cases {
+		var doc, expected interface{}
+		var ops []patchOp
+		json.Unmarshal([]byte(c.doc), &doc)
+		json.Unmarshal([]byte(c.patch), &ops)
+		json.Unmarshal([]byte(c.expected), &expected)
+		if actual, err := jsonPatch(doc, ops); err != nil {
+			t.Errorf("Patching %v with %v: %v", c.doc, c.patch, err)
+		} else if !reflect.DeepEqual(actual, expected) {
+			t.Errorf("Patching %v with %v, expected %v but got %v", c.doc, c.patch, expected, actual)
+		}
+	}
+}
+
+func Test_jsonPatch_Errors(t *testing.T) {
+	var doc interface{}
+	json.Unmarshal([]byte(`{"foo":"bar"}`), &doc)
+	_, err := jsonPatch(doc, []patchOp{{Op: "test", Path: "/foo", Value: "baz"}})
+	error_should_contain(t, err, "test failed")
+	_, err = jsonPatch(doc, []patchOp{{Op: "remove", Path: "/missing"}})
+	error_should_contain(t, err, "does not exist")
+}
+
+func Test_patchEntity(t *testing.T) {
+	v := &AppVersion{"test-app-1-2-3", "test-app", "1.2.3"}
+	body := ioutil.NopCloser(strings.NewReader(`{"id":"renamed"}`))
+	if patched, err := patchEntity(v, "application/merge-patch+json; charset=utf-8", body); err != nil {
+		t.Error(err)
+	} else if p := patched.(*AppVersion); p.ID != "renamed" || p.Version != "1.2.3" {
+		t.Errorf("Expected id to be renamed and version untouched, got %+v", p)
+	}
+	_, err := patchEntity(v, "application/json", ioutil.NopCloser(strings.NewReader(`{}`)))
+	if httpErr, ok := err.(HTTPError); !ok || httpErr.StatusCode() != 415 {
+		t.Errorf("Expected a 415 error, got %v", err)
+	}
+}
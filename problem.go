@@ -0,0 +1,109 @@
+package halgo
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+const ProblemMediaType = "application/problem+json"
+
+// Problem is an RFC 7807 problem details document. Every error served by a
+// Graph is rendered as one.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+	// Extensions are additional members, serialised alongside the standard
+	// ones above. They cannot replace the standard members.
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// ProblemExtender is implemented by errors which want to contribute to the
+// problem document they are rendered as, for example by setting a more
+// specific Type or adding Extensions.
+type ProblemExtender interface {
+	ExtendProblem(p *Problem)
+}
+
+// problemFor describes err as a Problem about the resource at instance. The
+// status comes from the first StatusCoder in err's chain, if it gives an
+// error status, otherwise it is 500.
+func problemFor(err error, instance string) Problem {
+	status := errorStatus(err)
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(status),
+		Status:   status,
+		Detail:   err.Error(),
+		Instance: instance,
+	}
+	var e ProblemExtender
+	if errors.As(err, &e) {
+		e.ExtendProblem(&p)
+	}
+	if p.Detail == p.Title {
+		p.Detail = ""
+	}
+	return p
+}
+
+// errorStatus is the status code err is served with, or 0 if err is nil.
+func errorStatus(err error) int {
+	var sc StatusCoder
+	if err == nil {
+		return 0
+	} else if errors.As(err, &sc) && sc.StatusCode() >= 400 && sc.StatusCode() <= 599 {
+		return sc.StatusCode()
+	}
+	return 500
+}
+
+func (p Problem) MarshalJSON() ([]byte, error) {
+	type standard Problem
+	buf, err := marshal(standard(p))
+	if err != nil || len(p.Extensions) == 0 {
+		return buf, err
+	}
+	out := bytes.NewBuffer(bytes.TrimSuffix(buf, []byte("}")))
+	for _, k := range sortedKeys(p.Extensions) {
+		switch k {
+		case "type", "title", "status", "detail", "instance":
+			continue
+		}
+		if name, err := marshal(k); err != nil {
+			return nil, err
+		} else if value, err := marshal(p.Extensions[k]); err != nil {
+			return nil, err
+		} else {
+			out.WriteByte(',')
+			out.Write(name)
+			out.WriteByte(':')
+			out.Write(value)
+		}
+	}
+	out.WriteByte('}')
+	return out.Bytes(), nil
+}
+
+// ExtendProblem lists the invalid fields in the problem's "errors" member.
+func (err ValidationError) ExtendProblem(p *Problem) {
+	p.Title = "Validation failed"
+	p.Detail = ""
+	p.Extensions = map[string]interface{}{"errors": err.Fields}
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	p := problemFor(err, r.URL.Path)
+	body, marshalErr := marshalIndent(p)
+	if marshalErr != nil {
+		// Only extensions can fail to serialise.
+		p.Extensions = nil
+		body, _ = marshalIndent(p)
+	}
+	w.Header().Set("Content-Type", ProblemMediaType)
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
@@ -0,0 +1,90 @@
+package halgo
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+)
+
+type teapotError struct{}
+
+func (teapotError) Error() string { return "I only make tea." }
+
+func (teapotError) ExtendProblem(p *Problem) {
+	p.Type = "https://example.com/problems/teapot"
+	p.Extensions = map[string]interface{}{"status": 999, "brew": "earl grey"}
+}
+
+func Test_problemFor(t *testing.T) {
+	p := problemFor(Error404("test-app"), "/apps/test-app")
+	expected := Problem{"about:blank", "Not Found", 404, "test-app not found.", "/apps/test-app", nil}
+	if p.Type != expected.Type || p.Title != expected.Title || p.Status != expected.Status ||
+		p.Detail != expected.Detail || p.Instance != expected.Instance {
+		t.Errorf("Expected %+v but got %+v", expected, p)
+	}
+	if p := problemFor(errors.New("disk full"), "/"); p.Status != 500 || p.Detail != "disk full" {
+		t.Errorf("Expected a 500 with detail 'disk full' but got %+v", p)
+	}
+	if p := problemFor(HttpError(404, "Not Found"), "/"); p.Detail != "" {
+		t.Errorf("Expected detail repeating the title to be omitted, but got %q", p.Detail)
+	}
+}
+
+type lockedError struct{}
+
+func (lockedError) Error() string   { return "record is locked" }
+func (lockedError) StatusCode() int { return 423 }
+
+func Test_problemFor_StatusCoder(t *testing.T) {
+	cases := []struct {
+		err      error
+		expected int
+	}{
+		{fmt.Errorf("loading app: %w", Error404("test-app")), 404},
+		{fmt.Errorf("saving: %w", lockedError{}), 423},
+		{fmt.Errorf("checking: %w", Invalid("/name", "is required")), 422},
+		{HttpError(200, "not an error status"), 500},
+	}
+	for _, c := range cases {
+		if p := problemFor(c.err, "/"); p.Status != c.expected {
+			t.Errorf("Expected %v for '%v' but got %v", c.expected, c.err, p.Status)
+		}
+	}
+}
+
+func Test_Problem_MarshalJSON(t *testing.T) {
+	buf, err := problemFor(teapotError{}, "/pot").MarshalJSON()
+	error_should_be_nil(t, err)
+	expected := `{"type":"https://example.com/problems/teapot","title":"Internal Server Error","status":500,` +
+		`"detail":"I only make tea.","instance":"/pot","brew":"earl grey"}`
+	if string(buf) != expected {
+		t.Errorf("Expected\n\t%s\nbut got\n\t%s", expected, buf)
+	}
+}
+
+func Test_writeError_ValidationError(t *testing.T) {
+	w := httptest.NewRecorder()
+	writeError(w, httptest.NewRequest("PUT", "/apps/test-app/x", nil), Invalid("/version", "is required"))
+	if w.Code != 422 {
+		t.Errorf("Expected 422 but got %v", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProblemMediaType {
+		t.Errorf("Expected %s but got %q", ProblemMediaType, ct)
+	}
+	expected := `{
+	"type": "about:blank",
+	"title": "Validation failed",
+	"status": 422,
+	"instance": "/apps/test-app/x",
+	"errors": [
+		{
+			"pointer": "/version",
+			"message": "is required"
+		}
+	]
+}`
+	if w.Body.String() != expected {
+		t.Errorf("Expected\n%s\nbut got\n%s", expected, w.Body.String())
+	}
+}
@@ -0,0 +1,203 @@
+package halgo
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Query string parameters understood by the Graph server.
+const (
+	EMBED  = "embed"
+	FIELDS = "fields"
+	CURSOR = "cursor"
+	LIMIT  = "limit"
+)
+
+// DefaultMaxEmbedDepth is the MaxEmbedDepth of nodes returned by Graph.
+const DefaultMaxEmbedDepth = 3
+
+// view describes how the client asked for a resource, and the resources
+// embedded in it, to be represented.
+type view struct {
+	// rel is the relation of the resource to its parent, or "" for the
+	// requested resource itself.
+	rel    string
+	embed  embedTree
+	fields sparseFields
+	page   page
+	filter Query
+	// query is the request's query string, which is carried over to paging
+	// links for the requested resource.
+	query url.Values
+	// req is the request being served, passed on to the Manifest methods of
+	// embedded children.
+	req *Request
+}
+
+// parseView reads the ?embed=, ?fields=, ?cursor=, ?limit=, ?filter= and
+// ?sort= parameters of a request for n. ?cursor= and ?limit= are ignored
+// unless n has a paged collection child.
+func (n *Node) parseView(r *Request, maxEmbedDepth int) (v view, err error) {
+	q := r.URL.Query()
+	v.req = r
+	if v.embed, err = parseEmbed(q[EMBED], maxEmbedDepth); err != nil {
+		return v, err
+	} else if err = n.verifyEmbed(v.embed); err != nil {
+		return v, err
+	} else if v.fields, err = parseFields(q); err != nil {
+		return v, err
+	} else if err = n.verifyFields(v.fields); err != nil {
+		return v, err
+	} else if v.filter, err = n.parseQuery(q); err != nil {
+		return v, err
+	} else {
+		v.query, v.page = q, firstPage
+		if n.ID_Child != nil && n.ID_Child.Page != nil {
+			v.page, err = parsePage(q)
+		}
+		return v, err
+	}
+}
+
+// child returns the view for a child embedded with the given rel. Embedded
+// collections always start at their first page, and are never filtered.
+func (v view) child(rel string, embed embedTree) view {
+	return view{rel, embed, v.fields, firstPage, Query{}, nil, v.req}
+}
+
+// canonical returns the view parameters of the request in a canonical form,
+// ignoring any other parameters.
+func (v view) canonical() string {
+	q := url.Values{}
+	for k, values := range v.query {
+		switch {
+		case k == EMBED, k == FIELDS, k == CURSOR, k == LIMIT, k == FILTER, k == SORT, strings.HasPrefix(k, FIELDS+"["):
+			q[k] = values
+		}
+	}
+	return q.Encode()
+}
+
+// project returns only the properties of m the client asked for, if it asked
+// for particular ones.
+func (v view) project(m map[string]interface{}) map[string]interface{} {
+	if names, ok := v.fields[v.rel]; ok {
+		return selectFields(m, names)
+	}
+	return m
+}
+
+// embedTree holds the children a client asked to embed, keyed by rel, each
+// with the children to embed beneath it. E.g. ?embed=apps.versions,health
+// is {"apps": {"versions": {}}, "health": {}}
+type embedTree map[string]embedTree
+
+func parseEmbed(values []string, maxDepth int) (embedTree, error) {
+	tree := embedTree{}
+	for _, v := range values {
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p == "" {
+				continue
+			}
+			rels := strings.Split(p, ".")
+			if len(rels) > maxDepth {
+				return nil, HttpError(400, "Cannot embed '", p, "', embedding is limited to ", maxDepth, " levels.")
+			}
+			t := tree
+			for _, rel := range rels {
+				if rel == "" {
+					return nil, HttpError(400, "Cannot embed '", p, "', it contains an empty rel.")
+				}
+				if _, ok := t[rel]; !ok {
+					t[rel] = embedTree{}
+				}
+				t = t[rel]
+			}
+		}
+	}
+	return tree, nil
+}
+
+// verifyEmbed checks that every rel in embed names a child of n.
+func (n *Node) verifyEmbed(embed embedTree) error {
+	for rel, sub := range embed {
+		if c, ok := n.Child(rel); !ok {
+			return HttpError(400, "Cannot embed '", rel, "', ", n.EntityType.Name(), " has no child with that rel.")
+		} else if err := c.Node.verifyEmbed(sub); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sparseFields holds the properties a client asked for, keyed by rel, with
+// "" being the requested resource itself.
+// E.g. ?fields=name&fields[versions]=version is {"": ["name"], "versions": ["version"]}
+type sparseFields map[string][]string
+
+func parseFields(q url.Values) (sparseFields, error) {
+	f := sparseFields{}
+	for k, values := range q {
+		rel := ""
+		if k == FIELDS {
+			// The requested resource itself.
+		} else if strings.HasPrefix(k, FIELDS+"[") && strings.HasSuffix(k, "]") {
+			rel = k[len(FIELDS)+1 : len(k)-1]
+		} else {
+			continue
+		}
+		for _, v := range values {
+			for _, name := range strings.Split(v, ",") {
+				if name = strings.TrimSpace(name); name != "" {
+					f[rel] = append(f[rel], name)
+				}
+			}
+		}
+		if len(f[rel]) == 0 {
+			return nil, HttpError(400, "Parameter '", k, "' must list at least one field.")
+		}
+	}
+	return f, nil
+}
+
+// verifyFields checks that every field asked for is a property of the
+// resources it applies to.
+func (n *Node) verifyFields(f sparseFields) error {
+	for rel, names := range f {
+		nodes := []*Node{n}
+		if rel != "" {
+			if nodes = n.descendants(rel); len(nodes) == 0 {
+				return HttpError(400, "Cannot select fields of '", rel, "', ", n.EntityType.Name(), " has no descendant with that rel.")
+			}
+		}
+		for _, d := range nodes {
+			for _, name := range names {
+				if !hasJSONProperty(d.EntityType, name) {
+					return HttpError(400, "Unknown field '", name, "', ", d.EntityType.Name(), " has no such property.")
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// descendants returns every node below n which is related to its parent by
+// rel.
+func (n *Node) descendants(rel string) []*Node {
+	found := []*Node{}
+	children := []*Child{}
+	if n.ID_Child != nil {
+		children = append(children, n.ID_Child)
+	} else {
+		for _, c := range *n.Children {
+			children = append(children, c)
+		}
+	}
+	for _, c := range children {
+		if c.Rel() == rel {
+			found = append(found, c.Node)
+		}
+		found = append(found, c.Node.descendants(rel)...)
+	}
+	return found
+}
@@ -0,0 +1,70 @@
+package halgo
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseEmbed(t *testing.T) {
+	tree, err := parseEmbed([]string{"apps.versions,health", "apps"}, 3)
+	error_should_be_nil(t, err)
+	expected := embedTree{"apps": embedTree{"versions": embedTree{}}, "health": embedTree{}}
+	if !reflect.DeepEqual(tree, expected) {
+		t.Errorf("Expected %v, got %v", expected, tree)
+	}
+	_, err = parseEmbed([]string{"a.b.c.d"}, 3)
+	error_should_contain(t, err, "limited to 3 levels")
+	_, err = parseEmbed([]string{"apps..versions"}, 3)
+	error_should_contain(t, err, "contains an empty rel")
+}
+
+func Test_parseFields(t *testing.T) {
+	q := url.Values{"fields": {"name"}, "fields[versions]": {"id,version"}, "embed": {"versions"}}
+	f, err := parseFields(q)
+	error_should_be_nil(t, err)
+	expected := sparseFields{"": {"name"}, "versions": {"id", "version"}}
+	if !reflect.DeepEqual(f, expected) {
+		t.Errorf("Expected %v, got %v", expected, f)
+	}
+	_, err = parseFields(url.Values{"fields[versions]": {""}})
+	error_should_contain(t, err, "must list at least one field")
+}
+
+func Test_ServeHTTP_Embed(t *testing.T) {
+	doc := halBody(t, serve(t, "GET", "/?embed=health", ""))
+	if hello := lookup(doc, "_embedded", "health", "Hello"); hello != "Feelin' good!" {
+		t.Errorf("Expected ?embed=health to embed the href-only health child but got %v", doc["_embedded"])
+	}
+	if w := serve(t, "GET", "/?embed=nope", ""); w.Code != 400 {
+		t.Errorf("Expected 400 for an unknown embed rel but got %v %s", w.Code, w.Body)
+	}
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Node().MaxEmbedDepth = 1
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/?embed=apps.apps", nil))
+	if w.Code != 400 || !strings.Contains(w.Body.String(), "limited to 1 levels") {
+		t.Errorf("Expected 400 beyond MaxEmbedDepth but got %v %s", w.Code, w.Body)
+	}
+}
+
+func Test_ServeHTTP_Fields(t *testing.T) {
+	doc := halBody(t, serve(t, "GET", "/apps/test-app?fields[versions]=version", ""))
+	versions, _ := lookup(doc, "_embedded", "versions").([]interface{})
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 embedded versions but got %v", doc)
+	}
+	for _, v := range versions {
+		if keys := sortedKeys(v.(map[string]interface{})); !reflect.DeepEqual(keys, []string{"_links", "version"}) {
+			t.Errorf("Expected only version and _links in each embedded version but got %v", v)
+		}
+	}
+	if doc["name"] != "test-app" {
+		t.Errorf("Expected fields[versions] to leave the app itself whole but got %v", doc)
+	}
+}
@@ -0,0 +1,49 @@
+package halgo
+
+import (
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// recoverPanic turns a panic while serving r, whether in a user method or an
+// encoder, into a 500 problem document. The panic is logged along with the
+// request path and stack. If the response had already been started, it is
+// only logged, as the problem would corrupt it. Deferred by ServeHTTP.
+func (root *Node) recoverPanic(w *startedResponseWriter, r *http.Request) {
+	p := recover()
+	if p == nil {
+		return
+	} else if p == http.ErrAbortHandler {
+		// net/http uses this to abort the response without logging.
+		panic(p)
+	}
+	format, args := "halgo: panic serving %s %s: %v\n%s", []interface{}{r.Method, r.URL.Path, p, debug.Stack()}
+	if root.Logger != nil {
+		root.Logger.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+	if root.Repanic {
+		panic(p)
+	} else if !w.started {
+		writeError(w, r, Error("The server panicked while serving this request."))
+	}
+}
+
+// startedResponseWriter records whether the response has been started, by
+// writing either its header or some of its body.
+type startedResponseWriter struct {
+	http.ResponseWriter
+	started bool
+}
+
+func (w *startedResponseWriter) WriteHeader(statusCode int) {
+	w.started = true
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *startedResponseWriter) Write(b []byte) (int, error) {
+	w.started = true
+	return w.ResponseWriter.Write(b)
+}
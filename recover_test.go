@@ -0,0 +1,83 @@
+package halgo
+
+import (
+	"bytes"
+	"log"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type Panicky struct {
+	Name string `json:"name"`
+}
+
+func (p *Panicky) Manifest() error {
+	panic("manifest exploded")
+}
+
+func panicky_graph(t *testing.T, logs *bytes.Buffer) *Node {
+	g, err := Graph(Panicky{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := g.Node()
+	n.Logger = log.New(logs, "", 0)
+	return n
+}
+
+func Test_ServeHTTP_RecoversPanics(t *testing.T) {
+	logs := &bytes.Buffer{}
+	w := httptest.NewRecorder()
+	panicky_graph(t, logs).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 500 {
+		t.Errorf("Expected 500 but got %v", w.Code)
+	}
+	if ct := w.Header().Get("Content-Type"); ct != ProblemMediaType {
+		t.Errorf("Expected %s but got %q", ProblemMediaType, ct)
+	}
+	for _, expected := range []string{"GET /: manifest exploded", "recoverPanic"} {
+		if !strings.Contains(logs.String(), expected) {
+			t.Errorf("Expected log containing %q but got\n%s", expected, logs)
+		}
+	}
+}
+
+func Test_ServeHTTP_Repanic(t *testing.T) {
+	n := panicky_graph(t, &bytes.Buffer{})
+	n.Repanic = true
+	defer func() {
+		if p := recover(); p != "manifest exploded" {
+			t.Errorf("Expected the panic to be re-raised, but got %v", p)
+		}
+	}()
+	n.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+// brokenWriter panics after writing the first part of the body.
+type brokenWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w brokenWriter) Write(b []byte) (int, error) {
+	w.ResponseRecorder.Write(b[:len(b)/2])
+	panic("connection exploded")
+}
+
+func Test_ServeHTTP_PanicAfterPartialWrite(t *testing.T) {
+	logs := &bytes.Buffer{}
+	g, err := Graph(Ledger{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := g.Node()
+	n.Logger = log.New(logs, "", 0)
+	w := httptest.NewRecorder()
+	n.ServeHTTP(brokenWriter{w}, httptest.NewRequest("GET", "/", nil))
+	if w.Code != 200 || strings.Contains(w.Body.String(), "problem") || strings.Contains(w.Body.String(), "panicked") {
+		t.Errorf("Expected the partial 200 to be left alone but got %v %s", w.Code, w.Body)
+	}
+	if !strings.Contains(logs.String(), "GET /: connection exploded") {
+		t.Errorf("Expected the panic to be logged but got\n%s", logs)
+	}
+}
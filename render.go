@@ -0,0 +1,299 @@
+package halgo
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Renderer serialises resources as a particular media type. Renderers are
+// chosen per request according to the Accept header.
+type Renderer interface {
+	MediaType() string
+	Render(*Resource) ([]byte, error)
+}
+
+// DefaultRenderers returns the renderers Graph installs, HAL JSON first so
+// that it is used when the client has no preference.
+func DefaultRenderers() []Renderer {
+	return []Renderer{HALJSONRenderer{}, JSONRenderer{}, HALXMLRenderer{}, SirenRenderer{}, JSONAPIRenderer{}}
+}
+
+// AddRenderer makes n available in the renderer's media type, replacing any
+// renderer already registered for it.
+func (n *Node) AddRenderer(r Renderer) {
+	for i, existing := range n.Renderers {
+		if existing.MediaType() == r.MediaType() {
+			n.Renderers[i] = r
+			return
+		}
+	}
+	n.Renderers = append(n.Renderers, r)
+}
+
+func (n *Node) mediaTypes() string {
+	types := list{}
+	for _, r := range n.Renderers {
+		types.Add(r.MediaType())
+	}
+	return types.String()
+}
+
+// negotiate picks the renderer the client most prefers according to the
+// Accept header. Ties go to the renderer registered first.
+func negotiate(accept string, renderers []Renderer) (Renderer, bool) {
+	if strings.TrimSpace(accept) == "" {
+		accept = "*/*"
+	}
+	ranges := parseAccept(accept)
+	var best Renderer
+	bestQ := 0.0
+	for _, r := range renderers {
+		if q := quality(r.MediaType(), ranges); q > bestQ {
+			best, bestQ = r, q
+		}
+	}
+	return best, best != nil
+}
+
+type mediaRange struct {
+	mediaType string
+	q         float64
+}
+
+func parseAccept(accept string) []mediaRange {
+	ranges := []mediaRange{}
+	for _, part := range strings.Split(accept, ",") {
+		params := strings.Split(part, ";")
+		mr := mediaRange{strings.ToLower(strings.TrimSpace(params[0])), 1}
+		for _, p := range params[1:] {
+			if kv := strings.SplitN(strings.TrimSpace(p), "=", 2); len(kv) == 2 && kv[0] == "q" {
+				if q, err := strconv.ParseFloat(kv[1], 64); err == nil {
+					mr.q = q
+				}
+			}
+		}
+		ranges = append(ranges, mr)
+	}
+	return ranges
+}
+
+// quality returns the q value the client gave mediaType, taken from the most
+// specific range matching it, or 0 if none do.
+func quality(mediaType string, ranges []mediaRange) float64 {
+	q, specificity := 0.0, -1
+	for _, mr := range ranges {
+		s := -1
+		if mr.mediaType == mediaType {
+			s = 2
+		} else if strings.HasSuffix(mr.mediaType, "/*") && strings.HasPrefix(mediaType, strings.TrimSuffix(mr.mediaType, "*")) {
+			s = 1
+		} else if mr.mediaType == "*/*" {
+			s = 0
+		}
+		if s > specificity {
+			q, specificity = mr.q, s
+		}
+	}
+	return q
+}
+
+// HALJSONRenderer renders application/hal+json.
+type HALJSONRenderer struct{}
+
+func (HALJSONRenderer) MediaType() string {
+	return "application/hal+json"
+}
+
+func (HALJSONRenderer) Render(r *Resource) ([]byte, error) {
+	return marshalIndent(r)
+}
+
+// JSONRenderer renders plain application/json, without any hypermedia.
+// Embedded resources appear as ordinary properties named after their rel.
+type JSONRenderer struct{}
+
+func (JSONRenderer) MediaType() string {
+	return "application/json"
+}
+
+func (JSONRenderer) Render(r *Resource) ([]byte, error) {
+	return marshalIndent(plain(r))
+}
+
+func plain(r *Resource) map[string]interface{} {
+	m := map[string]interface{}{}
+	for k, v := range r.Entity {
+		m[k] = v
+	}
+	for rel, e := range r.Embedded {
+		switch e := e.(type) {
+		case *Resource:
+			m[rel] = plain(e)
+		case []*Resource:
+			items := []interface{}{}
+			for _, item := range e {
+				items = append(items, plain(item))
+			}
+			m[rel] = items
+		}
+	}
+	return m
+}
+
+// HALXMLRenderer renders application/hal+xml. The self link becomes the href
+// of each <resource>, other links become <link> elements, and embedded
+// resources become nested <resource> elements carrying their rel. Properties
+// become elements of the same name, unless the name is not a valid XML name,
+// e.g. a map key like "1.2.3", in which case they become <property name="...">.
+type HALXMLRenderer struct{}
+
+func (HALXMLRenderer) MediaType() string {
+	return "application/hal+xml"
+}
+
+func (HALXMLRenderer) Render(r *Resource) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	e := xml.NewEncoder(buf)
+	e.Indent("", "\t")
+	if err := encodeXMLResource(e, "", r); err != nil {
+		return nil, err
+	} else if err := e.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeXMLResource(e *xml.Encoder, rel string, r *Resource) error {
+	start := xml.StartElement{Name: xml.Name{Local: "resource"}}
+	if rel != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "rel"}, Value: rel})
+	}
+	if self := r.selfHref(); self != "" {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "href"}, Value: self})
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	for _, rel := range sortedKeys(r.Links) {
+		if l, ok := r.Links[rel].(Link); ok && rel != "self" {
+			attrs := []xml.Attr{{Name: xml.Name{Local: "rel"}, Value: rel}, {Name: xml.Name{Local: "href"}, Value: l.Href}}
+			if l.Templated {
+				attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "templated"}, Value: "true"})
+			}
+			if err := encodeXMLElement(e, xml.StartElement{Name: xml.Name{Local: "link"}, Attr: attrs}, ""); err != nil {
+				return err
+			}
+		}
+	}
+	for _, k := range sortedKeys(r.Entity) {
+		if err := encodeXMLValue(e, k, r.Entity[k]); err != nil {
+			return err
+		}
+	}
+	for _, rel := range sortedKeys(r.Embedded) {
+		switch embedded := r.Embedded[rel].(type) {
+		case *Resource:
+			if err := encodeXMLResource(e, rel, embedded); err != nil {
+				return err
+			}
+		case []*Resource:
+			for _, item := range embedded {
+				if err := encodeXMLResource(e, rel, item); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// encodeXMLValue encodes a property, as decoded from JSON by toMap, as an
+// element named name. Arrays become repeated elements.
+func encodeXMLValue(e *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !isXMLName(name) {
+		start = xml.StartElement{Name: xml.Name{Local: "property"}, Attr: []xml.Attr{{Name: xml.Name{Local: "name"}, Value: name}}}
+	}
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if err := e.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range sortedKeys(v) {
+			if err := encodeXMLValue(e, k, v[k]); err != nil {
+				return err
+			}
+		}
+		return e.EncodeToken(start.End())
+	case []interface{}:
+		for _, item := range v {
+			if err := encodeXMLValue(e, name, item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case nil:
+		return encodeXMLElement(e, start, "")
+	default:
+		return encodeXMLElement(e, start, fmt.Sprint(v))
+	}
+}
+
+// isXMLName reports whether name can be used as an element name as it is.
+// Names with colons are excluded, as they would be read as namespaced, as are
+// names beginning "xml", which are reserved.
+func isXMLName(name string) bool {
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', unicode.IsLetter(r):
+		case i > 0 && (r == '-' || r == '.' || unicode.IsDigit(r)):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+func encodeXMLElement(e *xml.Encoder, start xml.StartElement, text string) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	} else if text != "" {
+		if err := e.EncodeToken(xml.CharData(text)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// selfHref returns the href of r's self link.
+func (r *Resource) selfHref() string {
+	if self, ok := r.Links["self"].(Link); ok {
+		return self.Href
+	}
+	return ""
+}
+
+// typeName returns the name of the Go type r was manifested from.
+func (r *Resource) typeName() string {
+	if r.Node == nil {
+		return ""
+	}
+	return r.Node.EntityType.Name()
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,157 @@
+package halgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func Test_negotiate(t *testing.T) {
+	renderers := DefaultRenderers()
+	cases := map[string]string{
+		"":                                      "application/hal+json",
+		"*/*":                                   "application/hal+json",
+		"application/json":                      "application/json",
+		"application/*;q=0.2, application/json": "application/json",
+		"application/hal+xml, application/json;q=0.5": "application/hal+xml",
+		"text/html, */*;q=0.1":                        "application/hal+json",
+		"application/hal+json;q=0, */*":               "application/json",
+	}
+	for accept, expected := range cases {
+		if r, ok := negotiate(accept, renderers); !ok {
+			t.Errorf("Accept: %q, expected %v but nothing matched", accept, expected)
+		} else if r.MediaType() != expected {
+			t.Errorf("Accept: %q, expected %v but got %v", accept, expected, r.MediaType())
+		}
+	}
+	if r, ok := negotiate("text/html", renderers); ok {
+		t.Errorf("Accept: text/html, expected no match but got %v", r.MediaType())
+	}
+}
+
+func Test_JSONRenderer_DropsHypermedia(t *testing.T) {
+	r := &Resource{
+		Entity:   map[string]interface{}{"name": "test-app"},
+		Links:    map[string]interface{}{"self": Link{Href: "/apps/test-app"}},
+		Embedded: map[string]interface{}{"versions": []*Resource{{Entity: map[string]interface{}{"version": "1.2.3"}}}},
+	}
+	if body, err := (JSONRenderer{}).Render(r); err != nil {
+		t.Error(err)
+	} else if expected := "{\n\t\"name\": \"test-app\",\n\t\"versions\": [\n\t\t{\n\t\t\t\"version\": \"1.2.3\"\n\t\t}\n\t]\n}"; string(body) != expected {
+		t.Errorf("Expected:\n%s\nGot:\n%s", expected, body)
+	}
+}
+
+func example_resource() *Resource {
+	n := &Node{EntityType: reflect.TypeOf(AppVersion{}), HTTPMethods: map[string]HTTPMethodDescriptor{GET: GET_desc, DELETE: DELETE_desc}}
+	return &Resource{
+		Node:   n,
+		Entity: map[string]interface{}{"name": "test-app"},
+		Links:  map[string]interface{}{"self": Link{Href: "/apps/test-app/1.2.3"}, "up": Link{Href: "/apps/test-app"}},
+	}
+}
+
+func Test_HALXMLRenderer_RoundTrips(t *testing.T) {
+	r := example_resource()
+	r.Entity["versions"] = map[string]interface{}{"1.2.3": map[string]interface{}{"version": "1.2.3"}, "team name": "deploy"}
+	body, err := (HALXMLRenderer{}).Render(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	elements, properties := []string{}, []string{}
+	for d := xml.NewDecoder(bytes.NewReader(body)); ; {
+		token, err := d.Token()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Expected well formed XML but got %v in\n%s", err, body)
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			elements = append(elements, start.Name.Local)
+			if start.Name.Local == "property" {
+				properties = append(properties, start.Attr[0].Value)
+			}
+		}
+	}
+	expected := []string{"resource", "link", "name", "versions", "property", "version", "property"}
+	if !reflect.DeepEqual(elements, expected) || !reflect.DeepEqual(properties, []string{"1.2.3", "team name"}) {
+		t.Errorf("Expected elements %v with properties [1.2.3 team name] but got %v %v in\n%s", expected, elements, properties, body)
+	}
+}
+
+func Test_SirenRenderer(t *testing.T) {
+	var e sirenEntity
+	if body, err := (SirenRenderer{}).Render(example_resource()); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(body, &e); err != nil {
+		t.Fatal(err)
+	}
+	if len(e.Class) != 1 || e.Class[0] != "AppVersion" {
+		t.Errorf("Expected class [AppVersion], got %v", e.Class)
+	}
+	if len(e.Links) != 2 {
+		t.Errorf("Expected self and up links, got %v", e.Links)
+	}
+	if len(e.Actions) != 1 || e.Actions[0].Method != DELETE || e.Actions[0].Href != "/apps/test-app/1.2.3" {
+		t.Errorf("Expected a single DELETE action, got %+v", e.Actions)
+	}
+	r := example_resource()
+	r.Node.HTTPMethods = map[string]HTTPMethodDescriptor{GET: GET_desc, PATCH: PATCH_desc, POST: POST_desc}
+	r.Node.PostedType = reflect.TypeOf(&Deployment{})
+	e = siren(r, "")
+	expected := []sirenAction{
+		{Name: "patch", Method: PATCH, Href: "/apps/test-app/1.2.3", Type: MergePatchType, Fields: []sirenField{{"name"}}},
+		{Name: "post", Method: POST, Href: "/apps/test-app/1.2.3", Type: "application/json", Fields: []sirenField{{"environment"}, {"version"}}},
+	}
+	if !reflect.DeepEqual(e.Actions, expected) {
+		t.Errorf("Expected actions\n\t%+v\nbut got\n\t%+v", expected, e.Actions)
+	}
+}
+
+func Test_JSONAPIRenderer(t *testing.T) {
+	r := example_resource()
+	r.Embedded = map[string]interface{}{"child": &Resource{Entity: map[string]interface{}{"a": 1.0}, Links: map[string]interface{}{"self": Link{Href: "/child"}}}}
+	var doc jsonAPIDocument
+	if body, err := (JSONAPIRenderer{}).Render(r); err != nil {
+		t.Fatal(err)
+	} else if err := json.Unmarshal(body, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Data.Type != "AppVersion" || doc.Data.ID != "/apps/test-app/1.2.3" {
+		t.Errorf("Expected AppVersion /apps/test-app/1.2.3, got %v %v", doc.Data.Type, doc.Data.ID)
+	}
+	if _, ok := doc.Data.Relationships["child"]; !ok {
+		t.Errorf("Expected a relationship named child, got %v", doc.Data.Relationships)
+	}
+	if len(doc.Included) != 1 || doc.Included[0].ID != "/child" {
+		t.Errorf("Expected /child to be included, got %+v", doc.Included)
+	}
+}
+
+func Test_JSONAPIRenderer_PagedCollection(t *testing.T) {
+	g, err := Graph(Shop{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("GET", "/?cursor=apple&limit=1", nil)
+	r.Header.Set("Accept", "application/vnd.api+json")
+	g.ServeHTTP(w, r)
+	var doc jsonAPIDocument
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a JSON:API document but got %v %s", w.Code, w.Body)
+	}
+	expected := map[string]string{"self": "/", "first": "/?limit=1", "next": "/?cursor=banana&limit=1", "prev": "/?limit=1"}
+	if !reflect.DeepEqual(doc.Data.Links, expected) {
+		t.Errorf("Expected links %v but got %v", expected, doc.Data.Links)
+	}
+	for _, rel := range []string{"first", "next", "prev"} {
+		if _, ok := doc.Data.Relationships[rel]; ok {
+			t.Errorf("Expected %v to be a link, not a relationship", rel)
+		}
+	}
+}
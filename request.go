@@ -0,0 +1,75 @@
+package halgo
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+)
+
+// Request is the HTTP request being served. User methods may declare a
+// *halgo.Request parameter, in any position, to read its headers or query
+// parameters, e.g.
+//
+//	func (a *App) Manifest(r *halgo.Request, parent *Apps, id string) error
+//
+// They may also declare a context.Context parameter, which is the request's
+// context, so that cancellation reaches their storage calls. Both may also be
+// declared by the Page and Query methods of collections.
+type Request struct {
+	*http.Request
+}
+
+var (
+	context_T = reflect.TypeOf((*context.Context)(nil)).Elem()
+	request_T = reflect.TypeOf(&Request{})
+)
+
+// context returns the request's context, or context.Background() outside of
+// a request.
+func (r *Request) context() context.Context {
+	if r == nil || r.Request == nil {
+		return context.Background()
+	}
+	return r.Context()
+}
+
+// injectedArgs matches the parameters of mt, a method type including its
+// receiver, against want, allowing a context.Context and a *Request in any
+// position. If they match, it returns a function building the method's
+// arguments from the request and values of the types in want.
+func injectedArgs(mt reflect.Type, want ...reflect.Type) (func(*Request, ...interface{}) []reflect.Value, bool) {
+	// positions holds each parameter's index in want, or -1 for the context
+	// and -2 for the request.
+	positions := []int{}
+	next := 0
+	for i := 1; i < mt.NumIn(); i++ {
+		switch t := mt.In(i); {
+		case t == context_T:
+			positions = append(positions, -1)
+		case t == request_T:
+			positions = append(positions, -2)
+		case next < len(want) && t == want[next]:
+			positions = append(positions, next)
+			next++
+		default:
+			return nil, false
+		}
+	}
+	if next != len(want) {
+		return nil, false
+	}
+	return func(r *Request, values ...interface{}) []reflect.Value {
+		args := make([]reflect.Value, len(positions))
+		for i, p := range positions {
+			switch p {
+			case -1:
+				args[i] = reflect.ValueOf(r.context())
+			case -2:
+				args[i] = reflect.ValueOf(r)
+			default:
+				args[i] = reflect.ValueOf(values[p])
+			}
+		}
+		return args
+	}, true
+}
@@ -0,0 +1,152 @@
+package halgo
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type Library struct {
+	Name  string          `json:"name"`
+	Books map[string]Book `json:"books" halgo:"embed()"`
+}
+
+func (l *Library) Manifest(r *Request) error {
+	l.Name = "Central"
+	l.Books = map[string]Book{"a": {"A"}}
+	if r.URL.Query().Get("include_deleted") == "true" {
+		l.Books["b"] = Book{"B (deleted)"}
+	}
+	return nil
+}
+
+type Book struct {
+	Title string `json:"title"`
+}
+
+// Manifest accepts its context between the usual parameters.
+func (b *Book) Manifest(parent *Library, ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if book, ok := parent.Books[id]; !ok {
+		return Error404(id)
+	} else {
+		*b = book
+	}
+	return nil
+}
+
+func Test_ServeHTTP_InjectsRequest(t *testing.T) {
+	g, err := Graph(Library{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		path     string
+		expected int
+	}{
+		{"/a", 200},
+		{"/b", 404},
+		{"/b?include_deleted=true", 200},
+	}
+	for _, c := range cases {
+		w := httptest.NewRecorder()
+		g.ServeHTTP(w, httptest.NewRequest("GET", c.path, nil))
+		if w.Code != c.expected {
+			t.Errorf("GET %s: expected %v but got %v", c.path, c.expected, w.Code)
+		}
+	}
+}
+
+func Test_ServeHTTP_InjectsContext(t *testing.T) {
+	g, err := Graph(Library{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/a", nil).WithContext(ctx))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "context canceled") {
+		t.Errorf("Expected a 500 caused by the cancelled context, but got %v %s", w.Code, w.Body)
+	}
+}
+
+type Archive struct {
+	Name  string  `json:"name"`
+	Boxes BoxList `json:"boxes" halgo:"embed()"`
+}
+
+func (a *Archive) Manifest() error {
+	a.Name = "Archive"
+	return nil
+}
+
+type Box struct {
+	Owner string `json:"owner"`
+}
+
+func (b *Box) Manifest(parent *Archive, id string) error {
+	return nil
+}
+
+type BoxList map[string]Box
+
+// Page serves the box of the owner named by ?owner=.
+func (l *BoxList) Page(r *Request, parent *Archive, cursor string, limit int) (Cursors, error) {
+	*l = BoxList{"a": {r.URL.Query().Get("owner")}}
+	return Cursors{}, nil
+}
+
+func (l *BoxList) Query(parent *Archive, q Query, ctx context.Context) (Cursors, error) {
+	if err := ctx.Err(); err != nil {
+		return Cursors{}, err
+	}
+	*l = BoxList{}
+	return Cursors{}, nil
+}
+
+func Test_ServeHTTP_InjectsIntoPageAndQuery(t *testing.T) {
+	g, err := Graph(Archive{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/?owner=sam", nil))
+	boxes, _ := halBody(t, w)["_embedded"].(map[string]interface{})["boxes"].([]interface{})
+	if len(boxes) != 1 || boxes[0].(map[string]interface{})["owner"] != "sam" {
+		t.Errorf("Expected sam's box but got %v %s", w.Code, w.Body)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/?sort=owner", nil).WithContext(ctx))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "context canceled") {
+		t.Errorf("Expected a 500 caused by the cancelled context, but got %v %s", w.Code, w.Body)
+	}
+}
+
+func Test_ServeHTTP_EmbedsMembersAsManifested(t *testing.T) {
+	g, err := Graph(Library{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	embedded := lookup(halBody(t, w), "_embedded", "books", "0")
+	get := httptest.NewRecorder()
+	g.ServeHTTP(get, httptest.NewRequest("GET", "/a", nil))
+	if got := halBody(t, get); !reflect.DeepEqual(embedded, got) {
+		t.Errorf("Expected embedded book\n\t%v\nto match GET /a\n\t%v", embedded, got)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w = httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest("GET", "/", nil).WithContext(ctx))
+	if w.Code != 500 || !strings.Contains(w.Body.String(), "context canceled") {
+		t.Errorf("Expected Book.Manifest to run for embedded books, but got %v %s", w.Code, w.Body)
+	}
+}
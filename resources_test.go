@@ -3,8 +3,8 @@ package halgo
 type RootResource struct {
 	Welcome string  `json:"welcome"`
 	Version string  `json:"version"`
-	Apps    *Apps   `json:"apps"    halgo:"embed()"`
-	health  *Health `               halgo:"link(rel=health) embed(href)"`
+	Apps    *Apps   `json:"apps"    halgo:"embed() cache(max-age=60, public)"`
+	health  *Health `               halgo:"link(rel=health) embed(href) cache(max-age=300, public)"`
 }
 
 func (RootResource) GET() (*RootResource, error) {
@@ -74,9 +74,9 @@ var the_apps = AppsList{
 }
 
 type AppVersion struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Version string `json:"version"`
+	ID      string `json:"id" validate:"required,max=64"`
+	Name    string `json:"name" validate:"required"`
+	Version string `json:"version" validate:"required,pattern=^\\d+\\.\\d+\\.\\d+$"`
 }
 
 func (AppVersion) GET(parentIDs map[string]string, version string) (*AppVersion, error) {
@@ -100,3 +100,8 @@ func (AppVersion) PUT(parentIDs map[string]string, version string, payload *AppV
 	}
 	return payload, nil
 }
+
+type Deployment struct {
+	Environment string `json:"environment" validate:"required,enum=staging|production"`
+	Version     string `json:"version"`
+}
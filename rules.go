@@ -0,0 +1,251 @@
+package halgo
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Fields may declare validation rules with a `validate` tag, which are checked
+// whenever a request body is decoded (PUT, POST and PATCH), before any user
+// Validate method runs. Rules are comma separated:
+//
+//	required      the field must not be its zero value
+//	min=N, max=N  bounds on the length of strings, slices and maps, or on the
+//	              value of numbers
+//	enum=a|b|c    the field must be one of the listed values
+//	pattern=RE    strings must match the regular expression RE; as RE may
+//	              itself contain commas, pattern must be the last rule
+//
+// E.g. `validate:"required,pattern=^\\d+\\.\\d+\\.\\d+$"` (note that backslashes
+// must be doubled inside struct tags).
+//
+// Zero values of fields which are not required are not checked against the
+// other rules. Nested structs are checked recursively, and all failures are
+// reported together in a single ValidationError.
+const VALIDATE_TAG = "validate"
+
+type rule func(v reflect.Value) (message string)
+
+type fieldRules struct {
+	index   int
+	name    string
+	require bool
+	rules   []rule
+	nested  *typeRules
+}
+
+type typeRules []fieldRules
+
+var (
+	rulesCache = map[reflect.Type]*typeRules{}
+	rulesLock  sync.Mutex
+)
+
+// rulesFor returns the compiled validation rules for struct type t, which may
+// be empty. Errors in validate tags are reported here.
+func rulesFor(t reflect.Type) (*typeRules, error) {
+	rulesLock.Lock()
+	defer rulesLock.Unlock()
+	return compileRules(t)
+}
+
+func compileRules(t reflect.Type) (*typeRules, error) {
+	if rs, ok := rulesCache[t]; ok {
+		return rs, nil
+	}
+	// Cache before compiling fields, so that recursive types terminate.
+	rs := &typeRules{}
+	rulesCache[t] = rs
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		fr := fieldRules{index: i, name: name}
+		if tag := f.Tag.Get(VALIDATE_TAG); tag != "" {
+			if err := fr.parse(f.Type, tag); err != nil {
+				delete(rulesCache, t)
+				return nil, Error(t, ".", f.Name, ": ", err)
+			}
+		}
+		if st := structType(f.Type); st != nil {
+			if nested, err := compileRules(st); err != nil {
+				delete(rulesCache, t)
+				return nil, err
+			} else {
+				fr.nested = nested
+			}
+		}
+		if fr.require || len(fr.rules) != 0 || fr.nested != nil {
+			*rs = append(*rs, fr)
+		}
+	}
+	return rs, nil
+}
+
+func structType(t reflect.Type) reflect.Type {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() == reflect.Struct {
+		return t
+	}
+	return nil
+}
+
+func (fr *fieldRules) parse(t reflect.Type, tag string) error {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	for tag != "" {
+		var r string
+		if strings.HasPrefix(tag, "pattern=") {
+			r, tag = tag, ""
+		} else if i := strings.Index(tag, ","); i >= 0 {
+			r, tag = tag[:i], tag[i+1:]
+		} else {
+			r, tag = tag, ""
+		}
+		name, arg := r, ""
+		if i := strings.Index(r, "="); i >= 0 {
+			name, arg = r[:i], r[i+1:]
+		}
+		if name == "required" {
+			fr.require = true
+		} else if compiled, err := compileRule(t, name, arg); err != nil {
+			return err
+		} else {
+			fr.rules = append(fr.rules, compiled)
+		}
+	}
+	return nil
+}
+
+func compileRule(t reflect.Type, name, arg string) (rule, error) {
+	switch name {
+	case "min", "max":
+		bound, err := strconv.ParseFloat(arg, 64)
+		if err != nil {
+			return nil, Error("invalid ", name, " '", arg, "'")
+		}
+		measure, what := measureFor(t)
+		if measure == nil {
+			return nil, Error(name, " does not apply to ", t)
+		}
+		if name == "min" {
+			return func(v reflect.Value) string {
+				if measure(v) < bound {
+					return fmt.Sprint(what, "must be at least ", arg)
+				}
+				return ""
+			}, nil
+		}
+		return func(v reflect.Value) string {
+			if measure(v) > bound {
+				return fmt.Sprint(what, "must be at most ", arg)
+			}
+			return ""
+		}, nil
+	case "enum":
+		options := strings.Split(arg, "|")
+		return func(v reflect.Value) string {
+			s := fmt.Sprint(v.Interface())
+			for _, o := range options {
+				if s == o {
+					return ""
+				}
+			}
+			return "must be one of " + strings.Join(options, ", ")
+		}, nil
+	case "pattern":
+		if t.Kind() != reflect.String {
+			return nil, Error("pattern does not apply to ", t)
+		}
+		re, err := regexp.Compile(arg)
+		if err != nil {
+			return nil, Error("invalid pattern: ", err)
+		}
+		return func(v reflect.Value) string {
+			if !re.MatchString(v.String()) {
+				return "must match " + arg
+			}
+			return ""
+		}, nil
+	default:
+		return nil, Error("unknown validation rule '", name, "'")
+	}
+}
+
+// measureFor returns how min and max measure values of type t.
+func measureFor(t reflect.Type) (func(reflect.Value) float64, string) {
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) float64 { return float64(utf8.RuneCountInString(v.String())) }, "length "
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return func(v reflect.Value) float64 { return float64(v.Len()) }, "length "
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) float64 { return float64(v.Int()) }, ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) float64 { return float64(v.Uint()) }, ""
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) float64 { return v.Float() }, ""
+	default:
+		return nil, ""
+	}
+}
+
+// checkRules checks entity, a struct or pointer to one, against the rules in
+// its validate tags, returning a ValidationError listing every failure.
+func checkRules(entity interface{}) error {
+	v := reflect.ValueOf(entity)
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	rs, err := rulesFor(v.Type())
+	if err != nil {
+		return err
+	}
+	var errs ValidationError
+	rs.check(v, "", &errs)
+	return errs.Err()
+}
+
+func (rs *typeRules) check(v reflect.Value, pointer string, errs *ValidationError) {
+	for _, fr := range *rs {
+		f := v.Field(fr.index)
+		p := pointer + "/" + escapePointer(fr.name)
+		if f.IsZero() {
+			if fr.require {
+				errs.Add(p, "is required")
+			}
+			continue
+		}
+		if f.Kind() == reflect.Ptr {
+			f = f.Elem()
+		}
+		for _, r := range fr.rules {
+			if message := r(f); message != "" {
+				errs.Add(p, message)
+			}
+		}
+		if fr.nested != nil {
+			fr.nested.check(f, p, errs)
+		}
+	}
+}
+
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,64 @@
+package halgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+type ruled struct {
+	Name     string   `json:"name" validate:"required,min=2,max=5"`
+	Count    int      `json:"count" validate:"max=10"`
+	Tags     []string `json:"tags" validate:"max=1"`
+	Colour   *string  `json:"colour" validate:"enum=red|green"`
+	Version  string   `json:"version" validate:"pattern=^\\d+(,\\d+)?$"`
+	Location *ruledLocation
+}
+
+type ruledLocation struct {
+	City string `json:"city" validate:"required"`
+}
+
+func Test_checkRules(t *testing.T) {
+	blue := "blue"
+	err := checkRules(&ruled{
+		Name:     "toolong",
+		Count:    11,
+		Tags:     []string{"a", "b"},
+		Colour:   &blue,
+		Version:  "1,x",
+		Location: &ruledLocation{},
+	})
+	v, ok := err.(ValidationError)
+	if !ok {
+		t.Fatalf("Expected a ValidationError but got %v", err)
+	}
+	expected := []FieldError{
+		{"/name", "length must be at most 5"},
+		{"/count", "must be at most 10"},
+		{"/tags", "length must be at most 1"},
+		{"/colour", "must be one of red, green"},
+		{"/version", "must match ^\\d+(,\\d+)?$"},
+		{"/Location/city", "is required"},
+	}
+	if !reflect.DeepEqual(v.Fields, expected) {
+		t.Errorf("Expected\n\t%v\nbut got\n\t%v", expected, v.Fields)
+	}
+
+	error_should_contain(t, checkRules(&ruled{}), "/name: is required")
+	error_should_be_nil(t, checkRules(&ruled{Name: "ok", Version: "1,2"}))
+}
+
+func Test_rulesFor_Errors(t *testing.T) {
+	_, err := rulesFor(reflect.TypeOf(struct {
+		A bool `validate:"min=1"`
+	}{}))
+	error_should_contain(t, err, "min does not apply to bool")
+	_, err = rulesFor(reflect.TypeOf(struct {
+		A string `validate:"sometimes"`
+	}{}))
+	error_should_contain(t, err, "unknown validation rule 'sometimes'")
+	_, err = rulesFor(reflect.TypeOf(struct {
+		A string `validate:"pattern=("`
+	}{}))
+	error_should_contain(t, err, "invalid pattern")
+}
@@ -0,0 +1,130 @@
+package halgo
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// SirenRenderer renders application/vnd.siren+json. Embedded resources become
+// sub-entities, and each supported method other than GET and HEAD becomes an
+// action on the entity's own href. Templated links are omitted, since Siren
+// has no notion of them.
+type SirenRenderer struct{}
+
+func (SirenRenderer) MediaType() string {
+	return "application/vnd.siren+json"
+}
+
+func (SirenRenderer) Render(r *Resource) ([]byte, error) {
+	return marshalIndent(siren(r, ""))
+}
+
+type sirenEntity struct {
+	Class      []string               `json:"class,omitempty"`
+	Rel        []string               `json:"rel,omitempty"`
+	Href       string                 `json:"href,omitempty"`
+	Properties map[string]interface{} `json:"properties,omitempty"`
+	Entities   []sirenEntity          `json:"entities,omitempty"`
+	Links      []sirenLink            `json:"links,omitempty"`
+	Actions    []sirenAction          `json:"actions,omitempty"`
+}
+
+type sirenLink struct {
+	Rel  []string `json:"rel"`
+	Href string   `json:"href"`
+}
+
+type sirenAction struct {
+	Name   string       `json:"name"`
+	Method string       `json:"method"`
+	Href   string       `json:"href"`
+	Type   string       `json:"type,omitempty"`
+	Fields []sirenField `json:"fields,omitempty"`
+}
+
+type sirenField struct {
+	Name string `json:"name"`
+}
+
+func siren(r *Resource, rel string) sirenEntity {
+	e := sirenEntity{}
+	if t := r.typeName(); t != "" {
+		e.Class = []string{t}
+	}
+	if rel != "" {
+		e.Rel = []string{rel}
+		if r.Entity == nil {
+			// Resources with nothing but a self link are embedded links.
+			e.Href = r.selfHref()
+			return e
+		}
+	}
+	e.Properties = r.Entity
+	for _, rel := range sortedKeys(r.Embedded) {
+		switch embedded := r.Embedded[rel].(type) {
+		case *Resource:
+			e.Entities = append(e.Entities, siren(embedded, rel))
+		case []*Resource:
+			for _, item := range embedded {
+				e.Entities = append(e.Entities, siren(item, rel))
+			}
+		}
+	}
+	for _, rel := range sortedKeys(r.Links) {
+		if l, ok := r.Links[rel].(Link); ok && !l.Templated {
+			e.Links = append(e.Links, sirenLink{[]string{rel}, l.Href})
+		}
+	}
+	e.Actions = sirenActions(r)
+	return e
+}
+
+func sirenActions(r *Resource) []sirenAction {
+	if r.Node == nil {
+		return nil
+	}
+	methods := []string{}
+	for m := range r.Node.HTTPMethods {
+		if m != GET && m != HEAD {
+			methods = append(methods, m)
+		}
+	}
+	sort.Strings(methods)
+	actions := []sirenAction{}
+	for _, m := range methods {
+		a := sirenAction{Name: strings.ToLower(m), Method: m, Href: r.selfHref()}
+		switch m {
+		case PUT, PATCH:
+			a.Type = "application/json"
+			if m == PATCH {
+				a.Type = MergePatchType
+			}
+			for _, k := range sortedKeys(r.Entity) {
+				a.Fields = append(a.Fields, sirenField{k})
+			}
+		case POST:
+			a.Type = "application/json"
+			a.Fields = postedFields(r.Node.PostedType)
+		}
+		actions = append(actions, a)
+	}
+	return actions
+}
+
+// postedFields lists the JSON properties of t, the type of a Process method's
+// posted body, or nothing if t is not a struct.
+func postedFields(t reflect.Type) []sirenField {
+	if t == nil {
+		return nil
+	} else if t = structType(t); t == nil {
+		return nil
+	}
+	fields := []sirenField{}
+	for i := 0; i < t.NumField(); i++ {
+		if name := jsonName(t.Field(i)); name != "" {
+			fields = append(fields, sirenField{name})
+		}
+	}
+	return fields
+}
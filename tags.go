@@ -2,6 +2,8 @@ package halgo
 
 import (
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -32,8 +34,10 @@ func fieldInfo(f reflect.StructField) field_info {
 }
 
 type meta struct {
-	expansion      *expansion
-	child_link_rel *string
+	expansion             *expansion
+	child_link_rel        *string
+	cache_control         *string
+	require_preconditions bool
 }
 
 type link struct {
@@ -65,6 +69,7 @@ type child_resource struct {
 // e.g. `halgo:"embed(href)"`
 //      `halgo:"link(rel=health)"`
 //      `halgo:"embed(all) link(rel=apps)`
+//      `halgo:"embed() cache(max-age=60, public) preconditions(required)"`
 const whitespace_chars = " \t"
 
 type tags map[string]map[string]string
@@ -75,11 +80,7 @@ func parseTags(f reflect.StructField) (tags, error) {
 		return nil, nil
 	}
 	tags := tags{}
-	for _, c := range strings.Split(data, " ") {
-		c = strings.Trim(c, whitespace_chars)
-		if len(c) == 0 {
-			continue
-		}
+	for _, c := range splitTags(data) {
 		parts := strings.Split(strings.TrimSuffix(c, ")"), "(")
 		if !strings.HasSuffix(c, ")") || len(parts) != 2 {
 			return nil, Error("Malformed halgo tag '", c, "'. Tags must be in the format 'name(...)'")
@@ -93,6 +94,29 @@ func parseTags(f reflect.StructField) (tags, error) {
 	return tags, nil
 }
 
+// splitTags splits a halgo tag into its things, on whitespace outside of
+// parentheses, so that parameters may be separated by ", ".
+func splitTags(data string) []string {
+	things := []string{}
+	depth, start := 0, -1
+	for i, r := range data + " " {
+		if r == '(' {
+			depth++
+		} else if r == ')' {
+			depth--
+		}
+		if depth <= 0 && strings.ContainsRune(whitespace_chars, r) {
+			if start >= 0 {
+				things = append(things, data[start:i])
+			}
+			start = -1
+		} else if start < 0 {
+			start = i
+		}
+	}
+	return things
+}
+
 func parseTagParams(p string) (map[string]string, error) {
 	params := map[string]string{}
 	for _, s := range strings.Split(p, ",") {
@@ -115,8 +139,12 @@ func getMetadata(f reflect.StructField) (m meta, err error) {
 		return m, err
 	} else if child_link_rel, err := getChildLinkRel(tags); err != nil {
 		return m, err
+	} else if cache_control, err := getCacheControl(tags); err != nil {
+		return m, err
+	} else if require_preconditions, err := getRequirePreconditions(tags); err != nil {
+		return m, err
 	} else {
-		return meta{expansion, child_link_rel}, nil
+		return meta{expansion, child_link_rel, cache_control, require_preconditions}, nil
 	}
 }
 
@@ -130,10 +158,87 @@ func getChildLinkRel(t tags) (*string, error) {
 	}
 }
 
+// getCacheControl reads the Cache-Control header value from a cache() tag,
+// e.g. cache(max-age=60, public).
+func getCacheControl(t tags) (*string, error) {
+	c, ok := t["cache"]
+	if !ok {
+		return nil, nil
+	}
+	directives := []string{}
+	for _, name := range sortedParams(c) {
+		value := c[name]
+		if takesSeconds, known := cache_directives[name]; name == "" {
+			continue
+		} else if !known {
+			return nil, Error("Unknown cache directive '", name, "'.")
+		} else if takesSeconds {
+			if _, err := strconv.ParseUint(value, 10, 32); err != nil {
+				return nil, Error("Cache directive '", name, "' must be in the format ", name, "=seconds.")
+			}
+			directives = append(directives, name+"="+value)
+		} else if value != "" {
+			return nil, Error("Cache directive '", name, "' does not take a value.")
+		} else {
+			directives = append(directives, name)
+		}
+	}
+	if len(directives) == 0 {
+		return nil, Error("cache() must list at least one directive. E.g. cache(max-age=60, public).")
+	}
+	cache_control := strings.Join(directives, ", ")
+	return &cache_control, nil
+}
+
+// getRequirePreconditions reads a preconditions(required) tag, which makes
+// PUT, PATCH and DELETE of the field's node require If-Match or If-None-Match.
+func getRequirePreconditions(t tags) (bool, error) {
+	p, ok := t["preconditions"]
+	if !ok {
+		return false, nil
+	} else if _, required := p["required"]; !required || len(p) != 1 {
+		return false, Error("Preconditions must be specified as preconditions(required).")
+	}
+	return true, nil
+}
+
+// cache_directives maps the Cache-Control response directives allowed in a
+// cache() tag to whether they take a number of seconds.
+var cache_directives = map[string]bool{
+	"max-age":                true,
+	"s-maxage":               true,
+	"stale-while-revalidate": true,
+	"stale-if-error":         true,
+	"public":                 false,
+	"private":                false,
+	"no-cache":               false,
+	"no-store":               false,
+	"no-transform":           false,
+	"must-revalidate":        false,
+	"proxy-revalidate":       false,
+	"immutable":              false,
+}
+
+func sortedParams(params map[string]string) []string {
+	names := make([]string, 0, len(params))
+	for k := range params {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func getFieldExpansion(t tags, fi field_info) (*expansion, error) {
 	if embed, ok := t["embed"]; !ok {
 		return nil, nil
 	} else {
+		if _, ok := embed[string(fields)]; ok {
+			if names, err := getEmbeddedFields(embed, fi); err != nil {
+				return nil, err
+			} else {
+				return &expansion{fields, names, fi}, nil
+			}
+		}
 		if _, ok := embed[""]; ok {
 			return &expansion{all, nil, fi}, nil
 		}
@@ -143,10 +248,58 @@ func getFieldExpansion(t tags, fi field_info) (*expansion, error) {
 		if _, ok := embed[string(href)]; ok {
 			return &expansion{href, nil, fi}, nil
 		}
-		if _, ok := embed[string(fields)]; ok {
-			// TODO: FIELDS!!!
-			return &expansion{fields, nil, fi}, nil
-		}
 		return nil, Error("Embed type '", embed, " is not recognised.")
 	}
 }
+
+// getEmbeddedFields reads the property list from embed(fields=a,b,c). Since
+// tag parameters are also comma separated, the first name is the value of the
+// fields parameter, and the rest arrive as parameters without values.
+func getEmbeddedFields(embed map[string]string, fi field_info) ([]string, error) {
+	names := []string{}
+	for k, v := range embed {
+		if k == string(fields) {
+			names = append(names, v)
+		} else if v != "" {
+			return nil, Error("embed(fields=...) does not accept parameter '", k, "=", v, "'")
+		} else {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" {
+			return nil, Error("embed(fields=...) must list at least one property. E.g. embed(fields=name,version).")
+		} else if !hasJSONProperty(fi.UnderlyingType, name) {
+			return nil, Error("embed(fields=...) names '", name, "', which is not a property of ", fi.UnderlyingType)
+		}
+	}
+	return names, nil
+}
+
+func hasJSONProperty(t reflect.Type, name string) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+	for i := 0; i < t.NumField(); i++ {
+		if jsonName(t.Field(i)) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonName returns the name encoding/json uses for f, or "" if f is not
+// serialised at all.
+func jsonName(f reflect.StructField) string {
+	if f.PkgPath != "" {
+		return ""
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	} else if name == "" {
+		return f.Name
+	}
+	return name
+}
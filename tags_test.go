@@ -0,0 +1,83 @@
+package halgo
+
+import (
+	"reflect"
+	"testing"
+)
+
+type fields_tag_example struct {
+	Good    *Apps       `halgo:"embed(fields=numberOfApps)"`
+	Many    *AppVersion `halgo:"embed(fields=name,version)"`
+	Unknown *AppVersion `halgo:"embed(fields=name,colour)"`
+	Empty   *AppVersion `halgo:"embed(fields)"`
+}
+
+func fields_tag_example_meta(t *testing.T, name string) (meta, error) {
+	f, ok := reflect.TypeOf(fields_tag_example{}).FieldByName(name)
+	if !ok {
+		t.Fatalf("No field named %v", name)
+	}
+	return getMetadata(f)
+}
+
+func Test_getMetadata_EmbedFields(t *testing.T) {
+	if m, err := fields_tag_example_meta(t, "Good"); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(m.expansion.fields, []string{"numberOfApps"}) {
+		t.Errorf("Expected fields [numberOfApps], got %v", m.expansion.fields)
+	}
+	if m, err := fields_tag_example_meta(t, "Many"); err != nil {
+		t.Error(err)
+	} else if !reflect.DeepEqual(m.expansion.fields, []string{"name", "version"}) {
+		t.Errorf("Expected fields [name version], got %v", m.expansion.fields)
+	}
+	_, err := fields_tag_example_meta(t, "Unknown")
+	error_should_contain(t, err, "'colour', which is not a property of")
+	_, err = fields_tag_example_meta(t, "Empty")
+	error_should_contain(t, err, "must list at least one property")
+}
+
+type cache_tag_example struct {
+	Good    *Apps `halgo:"embed() cache(public, max-age=60)"`
+	Unknown *Apps `halgo:"cache(forever)"`
+	NoValue *Apps `halgo:"cache(max-age)"`
+	Value   *Apps `halgo:"cache(public=yes)"`
+}
+
+func Test_getMetadata_Cache(t *testing.T) {
+	f, _ := reflect.TypeOf(cache_tag_example{}).FieldByName("Good")
+	if m, err := getMetadata(f); err != nil {
+		t.Error(err)
+	} else if m.expansion == nil || m.cache_control == nil || *m.cache_control != "max-age=60, public" {
+		t.Errorf("Expected an embedded field with Cache-Control: max-age=60, public, got %+v", m)
+	}
+	for name, expected := range map[string]string{
+		"Unknown": "Unknown cache directive 'forever'",
+		"NoValue": "must be in the format max-age=seconds",
+		"Value":   "'public' does not take a value",
+	} {
+		f, _ := reflect.TypeOf(cache_tag_example{}).FieldByName(name)
+		_, err := getMetadata(f)
+		error_should_contain(t, err, expected)
+	}
+}
+
+type preconditions_tag_example struct {
+	Required *Apps `halgo:"embed() preconditions(required)"`
+	Optional *Apps `halgo:"embed()"`
+	Unknown  *Apps `halgo:"preconditions(sometimes)"`
+}
+
+func Test_getMetadata_Preconditions(t *testing.T) {
+	for name, expected := range map[string]bool{"Required": true, "Optional": false} {
+		f, _ := reflect.TypeOf(preconditions_tag_example{}).FieldByName(name)
+		if m, err := getMetadata(f); err != nil {
+			t.Error(err)
+		} else if m.require_preconditions != expected {
+			t.Errorf("%v: expected require_preconditions to be %v", name, expected)
+		}
+	}
+	f, _ := reflect.TypeOf(preconditions_tag_example{}).FieldByName("Unknown")
+	_, err := getMetadata(f)
+	error_should_contain(t, err, "preconditions(required)")
+}
@@ -1,6 +1,9 @@
 package halgo
 
 import (
+	"encoding/json"
+	"net/http/httptest"
+	"strconv"
 	"strings"
 	"testing"
 )
@@ -18,3 +21,44 @@ func error_should_be_nil(t *testing.T, err error) {
 		t.Errorf("Expected nil error but got \n\t'%v'", err)
 	}
 }
+
+// serve serves a request for target with body, which may be "", from a Graph
+// of RootResource.
+func serve(t *testing.T, method, target, body string) *httptest.ResponseRecorder {
+	g, err := Graph(RootResource{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	g.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return w
+}
+
+// halBody decodes the HAL document served by w.
+func halBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("Expected a JSON body but got %q: %v", w.Body, err)
+	}
+	return doc
+}
+
+// lookup walks doc, a decoded JSON document, by object keys and array
+// indexes, returning nil if any step is missing.
+func lookup(doc interface{}, path ...string) interface{} {
+	for _, step := range path {
+		switch d := doc.(type) {
+		case map[string]interface{}:
+			doc = d[step]
+		case []interface{}:
+			if i, err := strconv.Atoi(step); err != nil || i < 0 || i >= len(d) {
+				return nil
+			} else {
+				doc = d[i]
+			}
+		default:
+			return nil
+		}
+	}
+	return doc
+}